@@ -1,11 +1,16 @@
 package bios
 import (
+	"image/color"
 	"luna_l2/video"
+	"luna_l2/font"
 	"luna_l2/types"
 	"luna_l2/audio"
+	"luna_l2/dma"
+	"luna_l2/config"
 	"time"	
 	"os"
 	"fmt"
+	"strconv"
 )
 
 var TypeOut bool = false
@@ -13,13 +18,28 @@ var KeyTrap bool = false
 var Registers *[]types.Register
 var Memory *[0x70000000]byte
 var KeyInterruptCode uint32 = 0x5
+// ID of the core making the interrupt
+var CoreID func() uint32
+// Key source for interrupt 6, which returns 0 if it gives up
+var Input func() (uint32, bool)
+// Disk images, the first one is the boot disk
+var Disks []string
+var Devices config.Devices = config.Default().Devices
+// Used by interrupt 2, tests replace it so they do not wait
+var Sleep func(time.Duration) = time.Sleep
+// Used by interrupts 42 and 44 to wait for the next vertical blank
+var WaitVBlank func()
+// Called with the old value before the BIOS writes main memory
+var Written func(address uint32, old byte)
+// Called with the old value before the BIOS writes a register
+var RegisterWritten func(address uint32, old uint32)
 const (
 	MEMSIZE uint32 = 0x70000000
 	MEMCAP uint32 = 0x6FFFFFFF
 )
 
 func WriteChar(char string, fg uint8, bg uint8) {
-	video.PrintChar(rune(char[0]), byte(fg), byte(bg))
+	video.PrintChar([]rune(char)[0], byte(fg), byte(bg))
 }
 
 func WriteString(str string, fg uint8, bg uint8) {
@@ -35,6 +55,9 @@ func WriteLine(str string, fg uint8, bg uint8) {
 func setRegister(address uint32, value uint32) {
 	for i := range (*Registers) {
 		if (*Registers)[i].Address == address {
+			if RegisterWritten != nil {
+				RegisterWritten(address, (*Registers)[i].Value)
+			}
 			if types.Bits32 == false {
 				(*Registers)[i].Value = uint32(uint16(value))
 			} else {
@@ -53,6 +76,20 @@ func getRegister(address uint32) uint32 {
 	return 0x0000
 }
 
+// Writes a register-width value to device memory, big endian like main memory.
+// Bytes that fall past the end of the device are dropped.
+func writeDevice(memory []byte, address uint32, value uint32) {
+	var size uint32 = 2
+	if types.Bits32 == true {
+		size = 4
+	}
+	for i := uint32(0); i < size; i++ {
+		if address + i < uint32(len(memory)) {
+			memory[address + i] = byte(value >> ((size - 1 - i) * 8))
+		}
+	}
+}
+
 func IntHandler(code uint32) {
 	if code == 0x01 {
 		// BIOS print to screen
@@ -65,21 +102,11 @@ func IntHandler(code uint32) {
 		// BIOS sleep
 		// seconds in R1
 		timeToSleep := getRegister(0x0001)
-		time.Sleep(time.Duration(timeToSleep) * time.Millisecond)
+		Sleep(time.Duration(timeToSleep) * time.Millisecond)
 	} else if code == 0x03 {
 		// BIOS write to VRAM
 		// address in R1, word in R2
-		address := getRegister(0x0001)
-		word := getRegister(0x0002)
-		if types.Bits32 == false {
-			video.MemoryVideo[video.Clamp(address, 0, MEMCAP)] = byte(uint16(word) >> 8)
-			video.MemoryVideo[video.Clamp(address + 1, 0, MEMCAP)] = byte(uint16(word) & 0xFF)
-		} else {
-			video.MemoryVideo[video.Clamp(address, 0, MEMCAP)] = byte(uint32(word) >> 24)
-			video.MemoryVideo[video.Clamp(address + 1, 0, MEMCAP)] = byte(uint32(word) >> 16)
-			video.MemoryVideo[video.Clamp(address + 2, 0, MEMCAP)] = byte(uint32(word) >> 8)
-			video.MemoryVideo[video.Clamp(address + 3, 0, MEMCAP)] = byte(uint32(word) & 0xFF)
-		}
+		writeDevice(video.VRAM(), getRegister(0x0001), getRegister(0x0002))
 	} else if code == 0x4 {
 		// BIOS configure input mode
 		// Mode 1: no type output
@@ -102,6 +129,17 @@ func IntHandler(code uint32) {
 	} else if code == 0x6 {
 		// BIOS wait for key
 		// Return in R1 via interrupt 5
+		if Input != nil {
+			key, ok := Input()
+			if ok == false {
+				setRegister(0x0001, 0)
+				return
+			}
+			setRegister(0x001b, key)
+			KeyTrap = true
+			IntHandler(KeyInterruptCode)
+			return
+		}
 		KeyTrap = true
 		for {
 			if KeyTrap == true {
@@ -116,38 +154,334 @@ func IntHandler(code uint32) {
 	} else if code == 0x8 {
 		// BIOS write to ARAM
 		// address in R1, word in R2
-		address := getRegister(0x0001)
-		word := getRegister(0x0002)
-		if types.Bits32 == false {
-			audio.MemoryAudio[video.Clamp(address, 0, MEMCAP)] = byte(uint16(word) >> 8)
-			audio.MemoryAudio[video.Clamp(address + 1, 0, MEMCAP)] = byte(uint16(word) & 0xFF)
-		} else {
-			audio.MemoryAudio[video.Clamp(address, 0, MEMCAP)] = byte(uint32(word) >> 24)
-			audio.MemoryAudio[video.Clamp(address + 1, 0, MEMCAP)] = byte(uint32(word) >> 16)
-			audio.MemoryAudio[video.Clamp(address + 2, 0, MEMCAP)] = byte(uint32(word) >> 8)
-			audio.MemoryAudio[video.Clamp(address + 3, 0, MEMCAP)] = byte(uint32(word) & 0xFF)
-		}	
+		if Devices.Audio == true {
+			writeDevice(audio.MemoryAudio[:], getRegister(0x0001), getRegister(0x0002))
+		}
 	} else if code == 0x9 {
-		audio.Play()
+		if Devices.Audio == true {
+			audio.Play()
+		}
 	} else if code == 0xa {
 		if types.Bits32 == false {
 			setRegister(0x0001, 0xffff)
 		} else {
-			setRegister(0x0001, MEMSIZE)
+			setRegister(0x0001, types.MemorySize)
+		}
+	} else if code == 0xb {
+		// BIOS start DMA transfer
+		// Source address (or fill value) in R1, destination address in R2,
+		// length in R3, spaces in R4, completion message in R5 (0 for none)
+		// R4: source space in bits 0-3, destination space in bits 4-7,
+		// 0x100 fills the destination with the low byte of R1
+		// Returns 1 in R1 if the transfer started, 0 if busy or invalid
+		spaces := getRegister(0x0004)
+		transfer := dma.Transfer{
+			Source: getRegister(0x0001),
+			Destination: getRegister(0x0002),
+			Length: getRegister(0x0003),
+			From: spaces & 0xF,
+			To: (spaces >> 4) & 0xF,
+			Fill: spaces & 0x100 != 0,
+			Value: byte(getRegister(0x0001)),
+			Message: getRegister(0x0005),
+		}
+		if CoreID != nil {
+			transfer.Core = CoreID()
+		}
+		if Devices.DMA == true && dma.Start(transfer) == true {
+			setRegister(0x0001, 1)
+		} else {
+			setRegister(0x0001, 0)
+		}
+	} else if code == 0xc {
+		// BIOS DMA status
+		// Returns the bytes left to transfer in R1, 0 when idle
+		setRegister(0x0001, dma.Remaining())
+	} else if code == 0xd {
+		// BIOS read disk sector
+		// Disk in R1, sector in R2, destination address in R3
+		// Returns 1 in R1 if the sector was read, 0 otherwise
+		if ReadSector(getRegister(0x0001), getRegister(0x0002), getRegister(0x0003)) == true {
+			setRegister(0x0001, 1)
+		} else {
+			setRegister(0x0001, 0)
+		}
+	} else if code == 0xe {
+		// BIOS set cursor
+		// Column in R1, row in R2, clamped to the screen
+		video.SetCursor(int(min(getRegister(0x0001), 0xffff)), int(min(getRegister(0x0002), 0xffff)))
+	} else if code == 0xf {
+		// BIOS get cursor
+		// Returns the column in R1 and the row in R2
+		setRegister(0x0001, uint32(video.CursorX))
+		setRegister(0x0002, uint32(video.CursorY))
+	} else if code == 0x10 {
+		// BIOS read character
+		// Column in R1, row in R2
+		// Returns the character in R1, 0 if the cell holds no character
+		ch, ok := video.CharAt(video.VRAM(), video.ActiveMode, int(min(getRegister(0x0001), 0xffff)), int(min(getRegister(0x0002), 0xffff)))
+		if ok == false {
+			ch = 0
+		}
+		setRegister(0x0001, uint32(ch))
+	} else if code == 0x11 {
+		// BIOS print string
+		// Address of a NUL-terminated string in R1, foreground in R2,
+		// background in R3. At most 65536 characters are printed.
+		address := getRegister(0x0001)
+		for i := uint32(0); i < 0x10000; i++ {
+			if uint64(address) + uint64(i) >= uint64(types.MemorySize) || Memory[address + i] == 0 {
+				break
+			}
+			video.PrintChar(rune(Memory[address + i]), uint8(getRegister(0x0002)), uint8(getRegister(0x0003)))
+		}
+	} else if code == 0x12 {
+		// BIOS print number
+		// Value in R1, foreground in R2, background in R3, base (2 to 16) in
+		// R4, 0 means 10
+		base := getRegister(0x0004)
+		if base == 0 {
+			base = 10
+		}
+		if base >= 2 && base <= 16 {
+			WriteString(strconv.FormatUint(uint64(getRegister(0x0001)), int(base)), uint8(getRegister(0x0002)), uint8(getRegister(0x0003)))
+		}
+	} else if code == 0x13 {
+		// BIOS clear screen
+		// Colour in R1, moves the cursor to the top left
+		video.Clear(uint8(getRegister(0x0001)))
+	} else if code == 0x14 {
+		// BIOS cursor visibility
+		// 1 in R1 shows a blinking cursor in the window, 0 hides it
+		video.CursorVisible = getRegister(0x0001) == 1
+	} else if code == 0x15 {
+		// BIOS set palette entry
+		// Index in R1, red in R2, green in R3, blue in R4
+		video.Palette[uint8(getRegister(0x0001))] = color.NRGBA{uint8(getRegister(0x0002)), uint8(getRegister(0x0003)), uint8(getRegister(0x0004)), 255}
+	} else if code == 0x16 {
+		// BIOS get palette entry
+		// Index in R1
+		// Returns red in R1, green in R2, blue in R3
+		entry := video.Palette[uint8(getRegister(0x0001))]
+		setRegister(0x0001, uint32(entry.R))
+		setRegister(0x0002, uint32(entry.G))
+		setRegister(0x0003, uint32(entry.B))
+	} else if code == 0x17 {
+		// BIOS load palette
+		// Address of 256 red, green, blue triples (768 bytes) in R1
+		address := uint64(getRegister(0x0001))
+		if address + 768 <= uint64(types.MemorySize) {
+			for i := range video.Palette {
+				rgb := Memory[address + uint64(i) * 3:]
+				video.Palette[i] = color.NRGBA{rgb[0], rgb[1], rgb[2], 255}
+			}
+		}
+	} else if code == 0x18 {
+		// BIOS store palette
+		// Address for 256 red, green, blue triples (768 bytes) in R1
+		address := uint64(getRegister(0x0001))
+		if address + 768 <= uint64(types.MemorySize) {
+			for i, entry := range video.Palette {
+				for j, value := range []uint8 {entry.R, entry.G, entry.B} {
+					at := address + uint64(i) * 3 + uint64(j)
+					if Written != nil {
+						Written(uint32(at), Memory[at])
+					}
+					Memory[at] = value
+				}
+			}
+		}
+	} else if code == 0x19 {
+		// BIOS reset palette
+		video.InitializePalette()
+	} else if code == 0x1a {
+		// BIOS set video mode
+		// Mode in R1, clears the screen and resets the palette
+		// Returns 1 in R1 if the mode exists, 0 otherwise
+		if video.SetMode(int(min(getRegister(0x0001), 0xffff))) == true {
+			setRegister(0x0001, 1)
+		} else {
+			setRegister(0x0001, 0)
+		}
+	} else if code == 0x1b {
+		// BIOS get video mode
+		// Returns the mode in R1, width in R2, height in R3, console columns
+		// in R4 and rows in R5
+		mode := video.Modes[video.ActiveMode]
+		setRegister(0x0001, uint32(video.ActiveMode))
+		setRegister(0x0002, uint32(mode.Width))
+		setRegister(0x0003, uint32(mode.Height))
+		setRegister(0x0004, uint32(mode.Columns))
+		setRegister(0x0005, uint32(mode.Rows))
+	} else if code == 0x1c {
+		// BIOS set pixel
+		// X in R1, Y in R2, colour in R3
+		video.SetPixel(signed(0x0001), signed(0x0002), uint8(getRegister(0x0003)))
+	} else if code == 0x1d {
+		// BIOS get pixel
+		// X in R1, Y in R2
+		// Returns the colour in R1, 0 off the screen
+		setRegister(0x0001, uint32(video.GetPixel(signed(0x0001), signed(0x0002))))
+	} else if code == 0x1e {
+		// BIOS horizontal line
+		// X in R1, Y in R2, length in R3, colour in R4
+		video.HLine(signed(0x0001), signed(0x0002), signed(0x0003), uint8(getRegister(0x0004)))
+	} else if code == 0x1f {
+		// BIOS vertical line
+		// X in R1, Y in R2, length in R3, colour in R4
+		video.VLine(signed(0x0001), signed(0x0002), signed(0x0003), uint8(getRegister(0x0004)))
+	} else if code == 0x20 {
+		// BIOS line
+		// From R1, R2 to R3, R4, colour in R5
+		video.Line(signed(0x0001), signed(0x0002), signed(0x0003), signed(0x0004), uint8(getRegister(0x0005)))
+	} else if code == 0x21 || code == 0x22 {
+		// BIOS rectangle, 0x22 fills it
+		// X in R1, Y in R2, width in R3, height in R4, colour in R5
+		video.Rect(signed(0x0001), signed(0x0002), signed(0x0003), signed(0x0004), uint8(getRegister(0x0005)), code == 0x22)
+	} else if code == 0x23 || code == 0x24 {
+		// BIOS circle, 0x24 fills it
+		// Centre in R1, R2, radius in R3, colour in R4
+		video.Circle(signed(0x0001), signed(0x0002), signed(0x0003), uint8(getRegister(0x0004)), code == 0x24)
+	} else if code == 0x25 {
+		// BIOS blit
+		// Address of the pixels in R1, X in R2, Y in R3, width in R4, height
+		// in R5, key colour in R6 (above 255 for none)
+		// Does nothing if the pixels do not fit in memory
+		address := uint64(getRegister(0x0001))
+		width, height := uint64(getRegister(0x0004)), uint64(getRegister(0x0005))
+		key := int(getRegister(0x0006))
+		if key > 0xff {
+			key = -1
+		}
+		if address + width * height <= uint64(types.MemorySize) {
+			video.Blit(Memory[address:address + width * height], signed(0x0002), signed(0x0003), int(width), int(height), key)
+		}
+	} else if code == 0x26 {
+		// BIOS set sprite table
+		// Address in R1, number of sprites in R2 (0 turns them off)
+		// Returns 1 in R1, 0 if there are more than 64 sprites
+		if getRegister(0x0002) > uint32(video.MaxSprites) {
+			setRegister(0x0001, 0)
+			return
+		}
+		video.SpriteTable = getRegister(0x0001)
+		video.SpriteCount = int(getRegister(0x0002))
+		setRegister(0x0001, 1)
+	} else if code == 0x27 {
+		// BIOS set tile layer
+		// Address of the tiles in R1, address of the map in R2, map width in
+		// R3 and height in R4 (in tiles, 0 turns the layer off)
+		video.Layer.Tiles = getRegister(0x0001)
+		video.Layer.Map = getRegister(0x0002)
+		video.Layer.Width = int(min(getRegister(0x0003), 0xffff))
+		video.Layer.Height = int(min(getRegister(0x0004), 0xffff))
+	} else if code == 0x28 {
+		// BIOS scroll tile layer
+		// X in R1, Y in R2 (signed)
+		video.Layer.ScrollX = signed(0x0001)
+		video.Layer.ScrollY = signed(0x0002)
+	} else if code == 0x29 {
+		// BIOS sprite collisions
+		// Sprite in R1
+		// Returns in R1: bit 0 if it touches another sprite, bit 1 if it
+		// touches the tile layer
+		setRegister(0x0001, video.Collisions(int(min(getRegister(0x0001), 0xffff))))
+	} else if code == 0x2a {
+		// BIOS wait for vblank
+		if WaitVBlank != nil {
+			WaitVBlank()
 		}
+	} else if code == 0x2b {
+		// BIOS double buffering
+		// 1 in R1 shows the front buffer, which only changes on a flip, 0
+		// shows VRAM as it is drawn
+		video.SetDoubleBuffer(getRegister(0x0001) == 1)
+	} else if code == 0x2c {
+		// BIOS flip
+		// Shows VRAM at the next vblank and waits for it
+		video.Flip()
+		if WaitVBlank != nil {
+			WaitVBlank()
+		}
+	} else if code == 0x2d {
+		// BIOS vblank interrupt
+		// Message in R1 (0 turns it off), raised on the calling core
+		video.VBlankMessage = getRegister(0x0001)
+		if CoreID != nil {
+			video.VBlankCore = CoreID()
+		}
+	} else if code == 0x2e {
+		// BIOS frame count
+		// Returns the number of vblanks since the machine started in R1
+		setRegister(0x0001, uint32(video.FrameCount))
+	} else if code == 0x2f {
+		// BIOS set glyph
+		// Character in R1, address of 8 rows in R2, top row first with bit 7
+		// as the leftmost pixel
+		address := uint64(getRegister(0x0002))
+		if address + 8 <= uint64(types.MemorySize) {
+			font.Set(uint8(getRegister(0x0001)), [8]byte(Memory[address:address + 8]))
+		}
+	} else if code == 0x30 {
+		// BIOS get glyph
+		// Character in R1, address for the 8 rows in R2, laid out as in 47
+		address := uint64(getRegister(0x0002))
+		if address + 8 <= uint64(types.MemorySize) {
+			for i, row := range font.Get(uint8(getRegister(0x0001))) {
+				at := address + uint64(i)
+				if Written != nil {
+					Written(uint32(at), Memory[at])
+				}
+				Memory[at] = row
+			}
+		}
+	} else if code == 0x31 {
+		// BIOS reset font
+		// Puts back the font the machine started with
+		font.Reset()
 	}
 }
 
-func Splash() {
-	WriteLine("Luna L2", 255, 0)
-	WriteLine("BIOS: Integrated BIOS", 255, 0)	
-	WriteLine("Copyright (c) 2025 Luna Microsystems LLC\n", 255, 0)
+// Register as a signed number of the register width, for coordinates that can
+// be off the screen
+func signed(address uint32) int {
+	if types.Bits32 == false {
+		return int(int16(getRegister(address)))
+	}
+	return int(int32(getRegister(address)))
 }
 
-func CheckArgs() bool {
-	if len(os.Args) < 2 {
-		WriteLine("No bootable device", 255, 0)
+// Copies a 512 byte sector of a disk to memory, returns false if the disk
+// cannot be read or the sector is past its end
+func ReadSector(disk uint32, sector uint32, address uint32) bool {
+	if disk >= uint32(len(Disks)) {
+		return false
+	}
+	data, err := os.ReadFile(Disks[disk])
+	if err != nil {
 		return false
 	}
+	start := uint64(sector) * 512
+	if start >= uint64(len(data)) {
+		return false
+	}
+	end := start + 512
+	if end > uint64(len(data)) {
+		end = uint64(len(data))
+	}
+	for i := start; i < end; i++ {
+		if uint64(address) + i - start < uint64(types.MemorySize) {
+			if Written != nil {
+				Written(uint32(uint64(address) + i - start), Memory[uint64(address) + i - start])
+			}
+			Memory[uint64(address) + i - start] = data[i]
+		}
+	}
 	return true
 }
+
+func Splash() {
+	WriteLine("Luna L2", 255, 0)
+	WriteLine("BIOS: Integrated BIOS", 255, 0)	
+	WriteLine("Copyright (c) 2025 Luna Microsystems LLC\n", 255, 0)
+}
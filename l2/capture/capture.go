@@ -0,0 +1,96 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"os"
+	"luna_l2/video"
+)
+
+// Screenshot taken once the machine reaches a cycle
+var ScreenshotPath string = ""
+var ScreenshotAt uint64 = 0
+var taken bool = false
+
+// Animated GIF of the screen, one frame every FrameCycles cycles. Frames that
+// did not change extend the previous one instead.
+var VideoPath string = ""
+var FrameCycles uint64 = 115800
+// Length of a frame in hundredths of a second, as stored in the GIF
+var FrameDelay int = 10
+var nextFrame uint64 = 0
+var frames = []*image.Paletted {}
+var delays = []int {}
+
+// Drops the captures of the previous run
+func Reset() {
+	taken = false
+	nextFrame = 0
+	frames = []*image.Paletted {}
+	delays = []int {}
+}
+
+// Writes the screen to a PNG file
+func Screenshot(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return png.Encode(file, video.Image())
+}
+
+// Called by the CPU as the clock advances
+func Tick(cycles uint64) {
+	if ScreenshotPath != "" && taken == false && cycles >= ScreenshotAt {
+		taken = true
+		if err := Screenshot(ScreenshotPath); err != nil {
+			fmt.Println("luna-l2: could not write screenshot: " + err.Error())
+		}
+	}
+	// A long stall can pass several frames, they all show the same screen
+	for VideoPath != "" && cycles >= nextFrame {
+		frame := video.Image()
+		if len(frames) > 0 && video.SameImage(frames[len(frames) - 1], frame) == true {
+			delays[len(delays) - 1] += FrameDelay
+		} else {
+			frames = append(frames, frame)
+			delays = append(delays, FrameDelay)
+		}
+		nextFrame += FrameCycles
+	}
+}
+
+// Writes out the captures when the machine stops. A screenshot for a cycle
+// that was never reached is taken of the last screen.
+func Finish() {
+	if ScreenshotPath != "" && taken == false {
+		taken = true
+		fmt.Println("luna-l2: stopped before cycle " + fmt.Sprintf("%d", ScreenshotAt) + ", screenshot shows the last screen")
+		if err := Screenshot(ScreenshotPath); err != nil {
+			fmt.Println("luna-l2: could not write screenshot: " + err.Error())
+		}
+	}
+	if VideoPath != "" {
+		Tick(nextFrame)
+		file, err := os.Create(VideoPath)
+		if err != nil {
+			fmt.Println("luna-l2: could not write video: " + err.Error())
+			return
+		}
+		defer file.Close()
+		// Frames of different video modes have different sizes, the GIF is
+		// as large as the largest one
+		animation := &gif.GIF{Image: frames, Delay: delays}
+		for _, frame := range frames {
+			animation.Config.Width = max(animation.Config.Width, frame.Rect.Dx())
+			animation.Config.Height = max(animation.Config.Height, frame.Rect.Dy())
+		}
+		if err := gif.EncodeAll(file, animation); err != nil {
+			fmt.Println("luna-l2: could not write video: " + err.Error())
+		}
+		VideoPath = ""
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Devices that can be switched off
+type Devices struct {
+	Audio bool `json:"audio"`
+	DMA bool `json:"dma"`
+	Keyboard bool `json:"keyboard"`
+}
+
+// Machine profile for luna-l2, stored as JSON
+type Machine struct {
+	Speed int64 `json:"speed"`
+	Memory uint32 `json:"memory"`
+	Cores int `json:"cores"`
+	Scheduler string `json:"scheduler"`
+	// 0 picks a new seed for every run
+	Seed int64 `json:"seed"`
+	// Disks[0] is the boot disk, the rest can be read with BIOS interrupt 13
+	Disks []string `json:"disks"`
+	// ROM image loaded at address 0 instead of the boot sector
+	BIOS string `json:"bios"`
+	Devices Devices `json:"devices"`
+	Headless bool `json:"headless"`
+	Scale int `json:"scale"`
+	// PSF or BDF font replacing the built in one
+	Font string `json:"font"`
+	// Window options, see the display package
+	IntegerScale bool `json:"integer_scale"`
+	Aspect bool `json:"aspect"`
+	Fullscreen bool `json:"fullscreen"`
+	CRT bool `json:"crt"`
+	// Colour around the picture as RRGGBB
+	Border string `json:"border"`
+	// Vertical blanks per second of emulated time
+	FrameRate int `json:"frame_rate"`
+	// Draws the screen in the terminal, see the terminal package
+	Terminal bool `json:"terminal"`
+	TerminalColumns int `json:"terminal_columns"`
+	// Frames per second
+	TerminalRate int `json:"terminal_rate"`
+	// Host key names mapped to the character code the guest receives
+	Keys map[string]uint32 `json:"keys"`
+	Log bool `json:"log"`
+	Debug bool `json:"debug"`
+	// Steps the debugger can undo
+	History int `json:"history"`
+	// Limits for untrusted programs, 0 or "" means no limit
+	MaxInstructions uint64 `json:"max_instructions"`
+	MaxCycles uint64 `json:"max_cycles"`
+	// Wall clock time, such as "10s" or "1m30s"
+	Timeout string `json:"timeout"`
+}
+
+func Default() Machine {
+	return Machine{
+		Speed: 1158000,
+		Memory: 0x70000000,
+		Cores: 1,
+		Scheduler: "random",
+		Disks: []string {},
+		Devices: Devices{Audio: true, DMA: true, Keyboard: true},
+		Scale: 2,
+		Border: "000000",
+		FrameRate: 60,
+		TerminalColumns: 80,
+		TerminalRate: 10,
+		History: 10000,
+		Keys: map[string]uint32 {},
+	}
+}
+
+// Reads a profile on top of the defaults, fields missing from the file keep
+// their default values
+func Load(path string) (Machine, error) {
+	machine := Default()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return machine, err
+	}
+	err = json.Unmarshal(data, &machine)
+	return machine, err
+}
+
+func Dump(machine Machine) string {
+	data, _ := json.MarshalIndent(machine, "", "\t")
+	return string(data)
+}
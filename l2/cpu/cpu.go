@@ -0,0 +1,1232 @@
+package cpu
+
+import (
+	"time"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"luna_l2/bios"
+	"luna_l2/dma"
+	"luna_l2/video"
+	"luna_l2/types"
+)
+
+// Basic elements of CPU
+var Registers = []types.Register {
+	{Address: 0x0000, Name: "R0"},
+	{Address: 0x0001, Name: "R1"},
+	{Address: 0x0002, Name: "R2"},
+	{Address: 0x0003, Name: "R3"},
+	{Address: 0x0004, Name: "R4"},
+	{Address: 0x0005, Name: "R5"},
+	{Address: 0x0006, Name: "R6"},
+	{Address: 0x0007, Name: "R7"},
+	{Address: 0x0008, Name: "R8"},
+	{Address: 0x0009, Name: "R9"},
+	{Address: 0x000a, Name: "R10"},
+	{Address: 0x000b, Name: "R11"},
+	{Address: 0x000c, Name: "R12"},
+	{Address: 0x000d, Name: "T1"},
+	{Address: 0x000e, Name: "T2"},
+	{Address: 0x000f, Name: "T3"},
+	{Address: 0x0010, Name: "T4"},
+	{Address: 0x0011, Name: "T5"},
+	{Address: 0x0012, Name: "T6"},
+	{Address: 0x0013, Name: "T7"},
+	{Address: 0x0014, Name: "T8"},
+	{Address: 0x0015, Name: "T9"},
+	{Address: 0x0016, Name: "T10"},
+	{Address: 0x0017, Name: "T11"},
+	{Address: 0x0018, Name: "T12"},
+	{Address: 0x0019, Name: "SP"},
+	{Address: 0x001a, Name: "PC"},
+	{Address: 0x001b, Name: "RE1"},
+	{Address: 0x001c, Name: "RE2"},
+	{Address: 0x001d, Name: "RE3"},
+}
+
+var Memory [0x70000000]byte
+const (
+	MEMSIZE uint32 = 0x70000000
+	MEMCAP uint32 = 0x6FFFFFFF
+)
+
+// Register controls
+func SetRegister(address uint32, value uint32) {
+	for i := range Registers {
+		if Registers[i].Address == address {
+			rememberRegister(address, Registers[i].Value)
+			if types.Bits32 == false {
+				Registers[i].Value = uint32(uint16(value))
+			} else {
+				Registers[i].Value = value
+			}
+		}
+	}
+}
+
+func GetRegister(address uint32) uint32 {
+	for _, register := range Registers {
+		if register.Address == address {
+			return register.Value
+		}
+	}
+	return 0x0000
+}
+
+func getRegisterName[T uint32 | byte](address T) string {
+	addr := uint32(address)
+	for _, register := range Registers {
+		if register.Address == addr {
+			return register.Name
+		}
+	}
+	return ""
+}
+
+func Mapper(address uint32) byte {
+	if address < types.MemorySize {
+		return Memory[address]
+	} else {
+		return Memory[types.MemorySize - 1]
+	}
+}
+
+func MapperIndex(address uint32) uint32 {
+	if address < types.MemorySize {
+		return address
+	} else {
+		return types.MemorySize - 1
+	}
+}
+
+// Meta-code
+var LogOn bool = false
+var Debug bool = false
+var Headless bool = false
+// 0 runs as fast as the host allows
+var ClockSpeed int64 = 1158000
+func Log(text string) {
+	if LogOn == true {
+		if len(Cores) > 1 {
+			text = fmt.Sprintf("[core %d] ", Current.ID) + text
+		}
+		fmt.Println("\033[33m" + fmt.Sprintf("0x%08x: ", GetRegister(0x001a)) + text + "\033[0m")
+	}
+}
+
+// CPU code
+// Emulated cycles since power on
+var Cycles uint64 = 0
+var Instructions uint64 = 0
+// Limits for untrusted programs, 0 means no limit
+var MaxInstructions uint64 = 0
+var MaxCycles uint64 = 0
+var Timeout time.Duration = 0
+var TimedOut atomic.Bool
+// Reason the machine was stopped by a limit
+var Limit string = ""
+// Exit status when a limit stops the machine, the same as timeout(1)
+const ExitLimit int = 124
+
+// Called as the clock advances, with the cycle count
+var Clock func(cycles uint64)
+
+func stall(cycles int64) { 
+	Cycles += uint64(cycles)
+	dma.Tick(cycles)
+	vblank()
+	if Clock != nil {
+		Clock(Cycles)
+	}
+	if ClockSpeed == 0 {
+		return
+	}
+	cycleTime := int64(int(time.Second)) / ClockSpeed
+	time.Sleep(time.Duration(cycleTime * cycles))
+}
+
+// Shift and rotate operands
+// <op> <mode (01 immediate count or 02 register count)> <dst> <src> <count>
+func shiftOperands(ProgramCounter uint32) (uint32, uint32, uint32, string) {
+	mode := Mapper(ProgramCounter + 1)
+	dst := uint32(Mapper(ProgramCounter + 2))
+	src := uint32(Mapper(ProgramCounter + 3))
+	if mode == 0x02 {
+		frm := uint32(Mapper(ProgramCounter + 4))
+		return dst, src, GetRegister(frm), getRegisterName(frm)
+	}
+	count := uint32(Mapper(ProgramCounter + 4))
+	return dst, src, count, fmt.Sprintf("0x%02x", count)
+}
+
+func width() uint32 {
+	if types.Bits32 == false {
+		return 16
+	}
+	return 32
+}
+
+// Memory controls
+// Values wider than a byte are big endian: the most significant byte is at the
+// lowest address, the same order immediates are encoded in.
+func readMemory(address uint32, size uint32) uint32 {
+	var value uint32 = 0
+	for i := uint32(0); i < size; i++ {
+		value = value << 8 | uint32(Mapper(address + i))
+	}
+	return value
+}
+
+func writeMemory(address uint32, size uint32, value uint32) {
+	for i := uint32(0); i < size; i++ {
+		index := MapperIndex(address + i)
+		remember(index, Memory[index])
+		Memory[index] = byte(value >> ((size - 1 - i) * 8))
+	}
+}
+
+// Stack controls
+// The stack grows down one register width at a time.
+// Below address 0 it wraps around to the top of memory.
+func pushStack(value uint32) {
+	sp := GetRegister(0x0019)
+	if types.Bits32 == false {
+		sp = uint32(uint16(sp - 2))
+	} else {
+		sp = sp - 4
+		if sp > types.MemorySize - 4 {
+			sp = types.MemorySize - 4
+		}
+	}
+	writeMemory(sp, width() / 8, value)
+	SetRegister(0x0019, sp)
+}
+
+func popStack() uint32 {
+	sp := GetRegister(0x0019)
+	value := readMemory(sp, width() / 8)
+	if types.Bits32 == false {
+		sp = uint32(uint16(sp + 2))
+	} else {
+		sp = sp + 4
+		if sp >= types.MemorySize {
+			sp = 0
+		}
+	}
+	SetRegister(0x0019, sp)
+	return value
+}
+
+// Load and store operands
+// <op> <register> <base register> <offset (16 or 32 bit, signed)>
+// Returns the register, the effective address and the next instruction
+func memoryOperands(ProgramCounter uint32) (uint32, uint32, uint32) {
+	register := uint32(Mapper(ProgramCounter + 1))
+	base := GetRegister(uint32(Mapper(ProgramCounter + 2)))
+	if types.Bits32 == false {
+		offset := int16(readMemory(ProgramCounter + 3, 2))
+		return register, uint32(uint16(base + uint32(int32(offset)))), ProgramCounter + 5
+	}
+	return register, base + readMemory(ProgramCounter + 3, 4), ProgramCounter + 7
+}
+
+// Reports an illegal instruction, returns true if execution should stop
+func illegal(ProgramCounter uint32, op byte) bool {
+	SetRegister(0x0001, uint32(op))
+	Log("\033[31mIllegal instruction 0x" + fmt.Sprintf("%08x", uint32(op)) + "\033[33m")
+	bios.IntHandler(0x7)
+	if Debug == true {
+		Trap = "illegal instruction " + fmt.Sprintf("0x%02x", op)
+		SetRegister(0x001a, ProgramCounter + 1)
+		return false
+	}
+	return true
+}
+
+// PC-relative operands
+// Mode 03 is an 8-bit displacement, mode 04 is a 16 or 32-bit displacement
+// depending on the register width. Displacements are signed and relative to
+// the address right after the displacement, which is the next instruction.
+func relative(address uint32, mode byte) (uint32, uint32) {
+	if mode == 0x03 {
+		next := address + 1
+		return next + uint32(int32(int8(Mapper(address)))), next
+	}
+	if types.Bits32 == false {
+		next := address + 2
+		disp := int16(uint16(Mapper(address)) << 8 | uint16(Mapper(address + 1)))
+		return next + uint32(int32(disp)), next
+	}
+	next := address + 4
+	disp := uint32(Mapper(address)) << 24 | uint32(Mapper(address + 1)) << 16 | uint32(Mapper(address + 2)) << 8 | uint32(Mapper(address + 3))
+	return next + disp, next
+}
+
+// Interprets a register value as a signed number of the current register width
+func signed(value uint32) int32 {
+	if types.Bits32 == false {
+		return int32(int16(value))
+	}
+	return int32(value)
+}
+
+// Multi-core
+// Every core has its own register file laid out like Registers and its own
+// 16/32-bit mode, and they all share Memory. The cores are interleaved on one
+// host thread, so every instruction is atomic with respect to the other cores.
+type Core struct {
+	ID uint32
+	Registers []types.Register
+	Bits32 bool
+	Halted bool
+	Handler uint32
+	Pending []Interrupt
+}
+
+// An interrupt waiting for a core, from another core (IPI) or from a device
+type Interrupt struct {
+	Message uint32
+	IPI bool
+}
+
+var Cores = []*Core {}
+var Current *Core
+var CoreCount int = 1
+// Every secondary core gets a 4 KiB stack below 0x10000, which leaves room for
+// 15 of them before core 16 would wrap around to core 0's stack
+const MaxCores = 16
+// "random" runs each core for a random number of instructions (1-64) from a
+// seeded generator, "rr" runs one instruction per core in turn
+var Scheduler string = "random"
+var Seed int64 = time.Now().UnixNano()
+
+func InitializeCores() {
+	Cores = []*Core {}
+	for i := 0; i < CoreCount; i++ {
+		registers := make([]types.Register, len(Registers))
+		copy(registers, Registers)
+		core := &Core{ID: uint32(i), Registers: registers}
+		if i > 0 {
+			// Secondary cores wait for a startup IPI, each with its own 4 KiB stack
+			core.Halted = true
+			for j := range core.Registers {
+				if core.Registers[j].Address == 0x0019 {
+					core.Registers[j].Value = uint32(uint16(0x10000 - i * 0x1000))
+				}
+			}
+		}
+		Cores = append(Cores, core)
+	}
+	switchCore(Cores[0])
+}
+
+// Queues a device interrupt for a core, unknown cores are ignored
+func Raise(core uint32, message uint32) {
+	if core < uint32(len(Cores)) {
+		Cores[core].Pending = append(Cores[core].Pending, Interrupt{Message: message})
+	}
+}
+
+func switchCore(core *Core) {
+	if Current != nil {
+		Current.Bits32 = types.Bits32
+	}
+	Current = core
+	Registers = core.Registers
+	types.Bits32 = core.Bits32
+}
+
+// Delivers the oldest pending interrupt to the current core.
+// With a handler, the core pushes PC and the message and jumps to the handler.
+// A halted core without one starts running at the message of an IPI instead
+// (startup IPI); otherwise the interrupt is dropped.
+func deliverInterrupt() {
+	if len(Current.Pending) == 0 {
+		return
+	}
+	message := Current.Pending[0].Message
+	ipi := Current.Pending[0].IPI
+	Current.Pending = Current.Pending[1:]
+	if Current.Handler != 0 {
+		pushStack(GetRegister(0x001a))
+		pushStack(message)
+		SetRegister(0x001a, Current.Handler)
+		Current.Halted = false
+		Log("interrupt " + fmt.Sprintf("0x%08x", message))
+	} else if Current.Halted == true && ipi == true {
+		SetRegister(0x001a, message)
+		Current.Halted = false
+		Log("startup ipi " + fmt.Sprintf("0x%08x", message))
+	}
+}
+
+func runnable() bool {
+	for _, core := range Cores {
+		if core.Halted == false || len(core.Pending) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the cores until one of them stops the machine
+func Execute() {
+	rng := rand.New(rand.NewSource(Seed))
+	next := 0
+	for {
+		if runnable() == false {
+			// The debugger can still step back into the program
+			if Debug == true {
+				if debugIdle() == false {
+					return
+				}
+				if runnable() == true {
+					continue
+				}
+			}
+			// Every core is halted, keep the clock running while a device
+			// may still wake one up
+			if dma.Active != nil {
+				stall(64)
+				if checkLimits() == false {
+					return
+				}
+				continue
+			}
+			if vblankHandled() == true {
+				waitVBlank()
+				if checkLimits() == false {
+					return
+				}
+				continue
+			}
+			// Nothing can wake a headless machine
+			if Headless == true {
+				return
+			}
+			for {
+				time.Sleep(time.Second)
+			}
+		}
+
+		core := Cores[next]
+		next = (next + 1) % len(Cores)
+		if core.Halted == true && len(core.Pending) == 0 {
+			continue
+		}
+		switchCore(core)
+
+		quantum := 1
+		if Scheduler == "random" {
+			quantum = 1 + rng.Intn(64)
+		}
+		for i := 0; i < quantum; i++ {
+			if Debug == true && debugger() == false {
+				return
+			}
+			begin()
+			deliverKeys()
+			deliverInterrupt()
+			if Current.Halted == true {
+				end()
+				break
+			}
+			if step() == false {
+				end()
+				return
+			}
+			Instructions++
+			watch(end())
+			if checkLimits() == false {
+				return
+			}
+		}
+	}
+}
+
+// Returns false and sets Limit once a limit is reached
+func checkLimits() bool {
+	if MaxInstructions != 0 && Instructions >= MaxInstructions {
+		Limit = "instruction limit reached"
+	} else if MaxCycles != 0 && Cycles >= MaxCycles {
+		Limit = "cycle limit reached"
+	} else if TimedOut.Load() == true {
+		Limit = "timeout reached"
+	}
+	return Limit == ""
+}
+
+// Prints why the machine stopped and the registers of every core
+func Report(reason string) {
+	if Current != nil {
+		Current.Bits32 = types.Bits32
+	}
+	fmt.Println("luna-l2: " + reason + fmt.Sprintf(" after %d instructions, %d cycles", Instructions, Cycles))
+	for _, core := range Cores {
+		line := fmt.Sprintf("core %d:", core.ID)
+		for _, register := range core.Registers {
+			line += fmt.Sprintf(" %s=0x%08x", register.Name, register.Value)
+		}
+		fmt.Println(line)
+	}
+}
+
+// Points the BIOS and the DMA controller at this CPU
+func Connect() {
+	bios.Registers = &Registers
+	bios.Memory = &Memory
+	bios.CoreID = func() uint32 { return Current.ID }
+	dma.Memory = &Memory
+	video.Memory = &Memory
+	dma.Interrupt = Raise
+	dma.Written = remember
+	bios.Written = remember
+	bios.RegisterWritten = rememberRegister
+	bios.Input = waitKey
+	bios.WaitVBlank = waitVBlank
+}
+
+// Powers the machine off: clears the installed memory, registers, counters and
+// limits so another program can run from a clean state
+func Reset() {
+	for i := uint32(0); i < types.MemorySize; i++ {
+		Memory[i] = 0
+	}
+	for i := range Registers {
+		Registers[i].Value = 0
+	}
+	Cores = []*Core {}
+	Current = nil
+	types.Bits32 = false
+	Cycles = 0
+	lastVBlank = 0
+	Instructions = 0
+	MaxInstructions = 0
+	MaxCycles = 0
+	TimedOut.Store(false)
+	Limit = ""
+	dma.Active = nil
+	keyQueue = []uint32 {}
+	Replay = []KeyEvent {}
+	history = []*undo {}
+	entry = nil
+}
+
+// Executes one instruction on the current core, returns false if the machine
+// should stop
+func step() bool {
+	ProgramCounter := GetRegister(0x001a)
+	op := Mapper(ProgramCounter)
+
+	if ProgramCounter == 0x0000 {
+		codesect := uint32(Mapper(ProgramCounter)) << 8 | uint32(Mapper(ProgramCounter + 1))
+		SetRegister(0x001a, codesect)
+		return true
+	}
+
+	switch op {
+	case 0x00:
+		return false
+	case 0x01:
+		// MOV
+		mode := Mapper(ProgramCounter + 1)
+		dst := Mapper(ProgramCounter + 2)
+
+		if mode == 0x01 {
+			var imm uint32 = 0
+			var next uint32 = 0
+			if types.Bits32 == false {
+				imm = uint32(uint16(Mapper(ProgramCounter + 3)) << 8 | uint16(Mapper(ProgramCounter + 4)))
+				next = ProgramCounter + 5
+			} else {
+				imm = uint32(Mapper(ProgramCounter + 3)) << 24 | uint32(Mapper(ProgramCounter + 4))	<< 16 | uint32(Mapper(ProgramCounter + 5)) << 8 | uint32(Mapper(ProgramCounter + 6))
+				next = ProgramCounter + 7
+			}
+			SetRegister(uint32(dst), imm)
+			SetRegister(0x001a, next)
+			Log("mov " + getRegisterName(uint32(dst)) + ", " + fmt.Sprintf("0x%08x", imm))
+		} else if mode == 0x02 {
+			frm := uint32(Mapper(ProgramCounter+3))
+			SetRegister(uint32(dst), uint32(GetRegister(frm)))
+			SetRegister(0x001a, ProgramCounter+4)
+			Log("mov " + getRegisterName(uint32(dst)) + ", " + getRegisterName(frm))
+		}	
+		stall(4)
+	case 0x02:
+		// HLT
+		// Stops this core until an inter-processor interrupt wakes it
+		Log("hlt")
+		Current.Halted = true
+		SetRegister(0x001a, ProgramCounter+1)
+	case 0x03:
+		// JMP	
+		mode := Mapper(ProgramCounter+1)
+
+		if mode == 0x01 {
+			var loc uint32 = 0
+			if types.Bits32 == false {
+				loc = uint32(uint16(Mapper(ProgramCounter + 2)) << 8 | uint16(Mapper(ProgramCounter + 3)))
+			} else {
+				loc = uint32(Mapper(ProgramCounter + 2)) << 24 | uint32(Mapper(ProgramCounter + 3))	<< 16 | uint32(Mapper(ProgramCounter + 4)) << 8 | uint32(Mapper(ProgramCounter + 5))
+			}	
+			SetRegister(0x001a, loc)
+			Log("jmp " + fmt.Sprintf("0x%08x", loc))
+		} else if mode == 0x02 {
+			frm := uint32(Mapper(ProgramCounter+2))
+			loc := GetRegister(frm)	
+			SetRegister(0x001a, loc)
+			Log("jmp " + getRegisterName(frm))
+		} else if mode == 0x03 || mode == 0x04 {
+			loc, _ := relative(ProgramCounter + 2, mode)
+			SetRegister(0x001a, loc)
+			Log("jmp " + fmt.Sprintf("0x%08x", loc) + " (relative)")
+		}
+		stall(8)
+	case 0x04:
+		// INT
+		var code uint32 = 0
+		var next uint32 = 0
+		if types.Bits32 == false {
+			code = uint32(uint16(Mapper(ProgramCounter + 1)) << 8 | uint16(Mapper(ProgramCounter + 2)))
+			next = ProgramCounter + 3
+		} else {
+			code = uint32(Mapper(ProgramCounter + 1)) << 24 | uint32(Mapper(ProgramCounter + 2))	<< 16 | uint32(Mapper(ProgramCounter + 3)) << 8 | uint32(Mapper(ProgramCounter + 4))
+			next = ProgramCounter + 5
+		}
+		bios.IntHandler(code)
+		SetRegister(0x001a, next)
+		Log("int " + fmt.Sprintf("0x%08x", code))
+		stall(34)
+	case 0x05:
+		// JNZ
+		// jnz <mode (01 or 02)> <check register> <loc (register or raw addr)>
+		mode := Mapper(ProgramCounter+1)
+		checkRegister := Mapper(ProgramCounter+2)
+		var loc uint32 = 0
+		var not uint32 = 0
+
+		if mode == 0x01 {	
+			if types.Bits32 == false {
+				loc = uint32(uint16(Mapper(ProgramCounter + 3)) << 8 | uint16(Mapper(ProgramCounter + 4)))
+				not = ProgramCounter + 5
+			} else {
+				loc = uint32(Mapper(ProgramCounter + 3)) << 24 | uint32(Mapper(ProgramCounter + 4))	<< 16 | uint32(Mapper(ProgramCounter + 5)) << 8 | uint32(Mapper(ProgramCounter + 6))
+				not = ProgramCounter + 7
+			}	
+			Log("jnz " + getRegisterName(uint32(checkRegister)) + ", " + fmt.Sprintf("0x%08x", loc))
+		} else if mode == 0x02 {
+			frm := uint32(Mapper(ProgramCounter+3))
+			loc = GetRegister(frm)
+			not = ProgramCounter + 4
+			Log("jnz " + getRegisterName(uint32(checkRegister)) + ", " + getRegisterName(frm))
+		} else if mode == 0x03 || mode == 0x04 {
+			loc, not = relative(ProgramCounter + 3, mode)
+			Log("jnz " + getRegisterName(uint32(checkRegister)) + ", " + fmt.Sprintf("0x%08x", loc) + " (relative)")
+		}
+
+		if GetRegister(uint32(checkRegister)) != 0 {
+			SetRegister(0x001a, loc)
+		} else {
+			SetRegister(0x001a, not)
+		}
+		stall(8)
+	case 0x06:
+		// NOP
+		SetRegister(0x001a, ProgramCounter+1)
+		Log("nop")
+		stall(1)
+	case 0x07:
+		// CMP
+		// Syntax: CMP <to> <r1> <r2>
+		to := Mapper(ProgramCounter+1)
+		first := Mapper(ProgramCounter+2)
+		second := Mapper(ProgramCounter+3)
+		Log("cmp " + getRegisterName(uint32(to)) + ", " + getRegisterName(first) + ", " + getRegisterName(second))
+
+		if GetRegister(uint32(first)) == GetRegister(uint32(second)) {
+			SetRegister(uint32(to), uint32(1))
+		} else {
+			SetRegister(uint32(to), uint32(0))
+		}
+		SetRegister(0x001a, ProgramCounter+4)
+		stall(4)
+	case 0x08:
+		// JZ
+		// jz <mode (01 or 02)> <check register> <loc (register or raw addr)>
+		mode := Mapper(ProgramCounter+1)
+		checkRegister := Mapper(ProgramCounter+2)
+		var loc uint32 = 0
+		var not uint32 = 0
+
+		if mode == 0x01 {	
+			if types.Bits32 == false {
+				loc = uint32(uint16(Mapper(ProgramCounter + 3)) << 8 | uint16(Mapper(ProgramCounter + 4)))
+				not = ProgramCounter + 5
+			} else {
+				loc = uint32(Mapper(ProgramCounter + 3)) << 24 | uint32(Mapper(ProgramCounter + 4))	<< 16 | uint32(Mapper(ProgramCounter + 5)) << 8 | uint32(Mapper(ProgramCounter + 6))
+				not = ProgramCounter + 7
+			}	
+			Log("jz " + getRegisterName(checkRegister) + ", " + fmt.Sprintf("0x%08x", loc))
+		} else if mode == 0x02 {
+			frm := uint32(Mapper(ProgramCounter+3))
+			loc = GetRegister(frm)
+			not = ProgramCounter + 4
+			Log("jz " + getRegisterName(checkRegister) + ", " + getRegisterName(frm))
+		} else if mode == 0x03 || mode == 0x04 {
+			loc, not = relative(ProgramCounter + 3, mode)
+			Log("jz " + getRegisterName(checkRegister) + ", " + fmt.Sprintf("0x%08x", loc) + " (relative)")
+		}
+
+		if GetRegister(uint32(checkRegister)) == 0 {
+			SetRegister(0x001a, loc)
+		} else {
+			SetRegister(0x001a, not)
+		}
+		stall(8)
+	case 0x09:
+		// INC
+		// inc <register>
+		register := uint32(Mapper(ProgramCounter+1))
+		SetRegister(register, GetRegister(register)+1)
+		SetRegister(0x001a, ProgramCounter+2)
+		Log("inc " + getRegisterName(register))
+		stall(1)
+	case 0x0a:
+		// DEC
+		// dec <register>
+		register := uint32(Mapper(ProgramCounter+1))
+		SetRegister(register, GetRegister(register)-1)
+		SetRegister(0x001a, ProgramCounter+2)
+		Log("dec " + getRegisterName(register))
+		stall(1)
+	case 0x0b:
+		// PUSH
+		// push <mode> <immediate or register>
+		mode := Mapper(ProgramCounter + 1)
+		var value uint32	
+		if mode == 0x1 {	
+			var next uint32 = 0
+			if types.Bits32 == false {
+				value = uint32(uint16(Mapper(ProgramCounter + 2)) << 8 | uint16(Mapper(ProgramCounter + 3)))
+				next = ProgramCounter + 4
+			} else {
+				value = uint32(Mapper(ProgramCounter + 2)) << 24 | uint32(Mapper(ProgramCounter + 3))	<< 16 | uint32(Mapper(ProgramCounter + 4)) << 8 | uint32(Mapper(ProgramCounter + 5))
+				next = ProgramCounter + 6
+			}	
+			SetRegister(0x001a, next)
+			Log("push " + fmt.Sprintf("0x%08x", value))
+		} else if mode == 0x2 {
+			value = GetRegister(uint32(Mapper(ProgramCounter + 2)))
+			SetRegister(0x001a, ProgramCounter + 3)
+			Log("push " + getRegisterName(uint32(Mapper(ProgramCounter + 2))))
+		}	
+		pushStack(value)
+		stall(2)
+	case 0x0c:
+		// POP
+		// pop <register>	
+		register := Mapper(ProgramCounter + 1)
+		value := popStack()
+		Log("value: " + fmt.Sprintf("0x%08x", value))
+		SetRegister(uint32(register), uint32(value))
+		SetRegister(0x001a, ProgramCounter + 2)
+		Log("pop " + getRegisterName(register))
+		stall(2)
+	case 0x0d:
+		// ADD
+		// add <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), GetRegister(uint32(regone))+GetRegister(uint32(regtwo)))
+		SetRegister(0x001a, ProgramCounter+4)
+		Log("add " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(7)
+	case 0x0e:
+		// SUB
+		// SUB <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), GetRegister(uint32(regone))-GetRegister(uint32(regtwo)))
+		SetRegister(0x001a, ProgramCounter+4)
+		Log("sub " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(7)
+	case 0x0f:
+		// MUL
+		// mul <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), GetRegister(uint32(regone))*GetRegister(uint32(regtwo)))
+		SetRegister(0x001a, ProgramCounter+4)
+		Log("mul " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(70)
+	case 0x10:
+		// DIV
+		// div <register> <register> <register>
+		// A zero divisor stores 0
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		if GetRegister(uint32(regtwo)) == 0 {
+			SetRegister(uint32(toregister), 0)
+			Log("division by zero")
+		} else {
+			SetRegister(uint32(toregister), GetRegister(uint32(regone))/GetRegister(uint32(regtwo)))
+		}
+		SetRegister(0x001a, ProgramCounter+4)
+		Log("div " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(140)
+	case 0x11:
+		// IGT
+		// igt <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		if GetRegister(uint32(regone)) > GetRegister(uint32(regtwo)) {
+			SetRegister(uint32(toregister), uint32(1))
+		} else {
+			SetRegister(uint32(toregister), uint32(0))
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("igt " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(4)
+	case 0x12:
+		// ILT
+		// ilt <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		if GetRegister(uint32(regone)) < GetRegister(uint32(regtwo)) {
+			SetRegister(uint32(toregister), uint32(1))
+		} else {
+			SetRegister(uint32(toregister), uint32(0))
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("ilt " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(4)
+	case 0x13:
+		// AND
+		// and <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), GetRegister(uint32(regone)) & GetRegister(uint32(regtwo)))	
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("and " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(1)
+	case 0x14:
+		// OR
+		// or <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), GetRegister(uint32(regone)) | GetRegister(uint32(regtwo)))	
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("or " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(1)
+	case 0x15:
+		// NOR
+		// nor <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), ^(GetRegister(uint32(regone)) | GetRegister(uint32(regtwo))))	
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("nor " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(3)
+	case 0x16:
+		// NOT
+		// not <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		SetRegister(uint32(uint32(toregister)), ^GetRegister(uint32(regone)))	
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("not " + getRegisterName(toregister) + ", " + getRegisterName(regone))
+		stall(1)
+	case 0x17:
+		// XOR
+		// xor <register> <register> <register>
+		toregister := Mapper(ProgramCounter+1)
+		regone := Mapper(ProgramCounter+2)
+		regtwo := Mapper(ProgramCounter+3)
+		SetRegister(uint32(toregister), GetRegister(uint32(regone)) ^ GetRegister(uint32(regtwo)))	
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("xor " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(6)
+	case 0x18:
+		// LOD
+		// lod <addr (register)> <destination register>	
+		addr := GetRegister(uint32(Mapper(ProgramCounter + 1)))
+		toregister := uint32(Mapper(ProgramCounter + 2))
+		SetRegister(toregister, readMemory(addr, 1))
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("lod " + getRegisterName(uint32(Mapper(ProgramCounter + 1))) + ", " + getRegisterName(toregister))
+		stall(100)
+	case 0x19:
+		// STR
+		// str <addr (register)> <value (register)>	
+		// Stores the full register width
+		addr := GetRegister(uint32(Mapper(ProgramCounter + 1)))
+		value := uint32(Mapper(ProgramCounter + 2))
+		writeMemory(addr, width() / 8, GetRegister(value))
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("str " + getRegisterName(uint32(Mapper(ProgramCounter + 1))) + ", " + getRegisterName(value))
+		stall(100)
+	case 0x1a:
+		// LODF
+		// lodf <addr (register)> <destination register>
+		// Loads the full register width
+		addr := GetRegister(uint32(Mapper(ProgramCounter + 1)))
+		toregister := uint32(Mapper(ProgramCounter + 2))
+		SetRegister(toregister, readMemory(addr, width() / 8))
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("lodw " + getRegisterName(uint32(Mapper(ProgramCounter + 1))) + ", " + getRegisterName(toregister))
+		stall(100)
+	case 0x1b:
+		// SET
+		// set <00 or 01>
+		mode := uint32(Mapper(ProgramCounter + 1))
+		if mode == 0 {
+			types.Bits32 = false
+			Log("16 bit mode")
+		} else if mode == 1 {
+			types.Bits32 = true
+			Log("32 bit mode")
+		}
+		SetRegister(0x001a, ProgramCounter + 2)
+	case 0x1c:
+		// SHL
+		// shl <mode> <dst> <src> <count (immediate or register)>
+		dst, src, count, name := shiftOperands(ProgramCounter)
+		var value uint32 = 0
+		if count < width() {
+			value = GetRegister(src) << count
+		}
+		SetRegister(dst, value)
+		SetRegister(0x001a, ProgramCounter + 5)
+		Log("shl " + getRegisterName(dst) + ", " + getRegisterName(src) + ", " + name)
+		stall(2)
+	case 0x1d:
+		// SHR
+		// shr <mode> <dst> <src> <count (immediate or register)>
+		dst, src, count, name := shiftOperands(ProgramCounter)
+		var value uint32 = 0
+		if count < width() {
+			value = GetRegister(src) >> count
+		}
+		SetRegister(dst, value)
+		SetRegister(0x001a, ProgramCounter + 5)
+		Log("shr " + getRegisterName(dst) + ", " + getRegisterName(src) + ", " + name)
+		stall(2)
+	case 0x1e:
+		// SAR
+		// sar <mode> <dst> <src> <count (immediate or register)>
+		// Shifts in copies of the sign bit of the current register width
+		dst, src, count, name := shiftOperands(ProgramCounter)
+		count = video.Clamp(count, 0, width() - 1)
+		if types.Bits32 == false {
+			SetRegister(dst, uint32(int16(GetRegister(src)) >> count))
+		} else {
+			SetRegister(dst, uint32(int32(GetRegister(src)) >> count))
+		}
+		SetRegister(0x001a, ProgramCounter + 5)
+		Log("sar " + getRegisterName(dst) + ", " + getRegisterName(src) + ", " + name)
+		stall(2)
+	case 0x1f:
+		// ROL
+		// rol <mode> <dst> <src> <count (immediate or register)>
+		dst, src, count, name := shiftOperands(ProgramCounter)
+		count = count % width()
+		value := GetRegister(src)
+		if count != 0 {
+			value = value << count | value >> (width() - count)
+		}
+		SetRegister(dst, value)
+		SetRegister(0x001a, ProgramCounter + 5)
+		Log("rol " + getRegisterName(dst) + ", " + getRegisterName(src) + ", " + name)
+		stall(3)
+	case 0x20:
+		// ROR
+		// ror <mode> <dst> <src> <count (immediate or register)>
+		dst, src, count, name := shiftOperands(ProgramCounter)
+		count = count % width()
+		value := GetRegister(src)
+		if count != 0 {
+			value = value >> count | value << (width() - count)
+		}
+		SetRegister(dst, value)
+		SetRegister(0x001a, ProgramCounter + 5)
+		Log("ror " + getRegisterName(dst) + ", " + getRegisterName(src) + ", " + name)
+		stall(3)
+	case 0x21:
+		// SGT
+		// sgt <register> <register> <register>
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		regtwo := Mapper(ProgramCounter + 3)
+		if signed(GetRegister(uint32(regone))) > signed(GetRegister(uint32(regtwo))) {
+			SetRegister(uint32(toregister), uint32(1))
+		} else {
+			SetRegister(uint32(toregister), uint32(0))
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("sgt " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(4)
+	case 0x22:
+		// SLT
+		// slt <register> <register> <register>
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		regtwo := Mapper(ProgramCounter + 3)
+		if signed(GetRegister(uint32(regone))) < signed(GetRegister(uint32(regtwo))) {
+			SetRegister(uint32(toregister), uint32(1))
+		} else {
+			SetRegister(uint32(toregister), uint32(0))
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("slt " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(4)
+	case 0x23:
+		// SDIV
+		// sdiv <register> <register> <register>
+		// Rounds towards zero; a zero divisor stores 0
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		regtwo := Mapper(ProgramCounter + 3)
+		divisor := signed(GetRegister(uint32(regtwo)))
+		if divisor == 0 {
+			SetRegister(uint32(toregister), 0)
+			Log("division by zero")
+		} else {
+			SetRegister(uint32(toregister), uint32(signed(GetRegister(uint32(regone))) / divisor))
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("sdiv " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(140)
+	case 0x24:
+		// MOD
+		// mod <register> <register> <register>
+		// Unsigned remainder; a zero divisor stores 0
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		regtwo := Mapper(ProgramCounter + 3)
+		divisor := GetRegister(uint32(regtwo))
+		if divisor == 0 {
+			SetRegister(uint32(toregister), 0)
+			Log("division by zero")
+		} else {
+			SetRegister(uint32(toregister), GetRegister(uint32(regone)) % divisor)
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("mod " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(140)
+	case 0x25:
+		// SMOD
+		// smod <register> <register> <register>
+		// Signed remainder, takes the sign of the dividend; a zero divisor stores 0
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		regtwo := Mapper(ProgramCounter + 3)
+		divisor := signed(GetRegister(uint32(regtwo)))
+		if divisor == 0 {
+			SetRegister(uint32(toregister), 0)
+			Log("division by zero")
+		} else {
+			SetRegister(uint32(toregister), uint32(signed(GetRegister(uint32(regone))) % divisor))
+		}
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("smod " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
+		stall(140)
+	case 0x26:
+		// SXB
+		// sxb <register> <register>
+		// Sign-extends the low byte to the register width
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		SetRegister(uint32(toregister), uint32(int32(int8(GetRegister(uint32(regone))))))
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("sxb " + getRegisterName(toregister) + ", " + getRegisterName(regone))
+		stall(1)
+	case 0x27:
+		// SXW
+		// sxw <register> <register>
+		// Sign-extends the low 16 bits to the register width
+		toregister := Mapper(ProgramCounter + 1)
+		regone := Mapper(ProgramCounter + 2)
+		SetRegister(uint32(toregister), uint32(int32(int16(GetRegister(uint32(regone))))))
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("sxw " + getRegisterName(toregister) + ", " + getRegisterName(regone))
+		stall(1)
+	case 0x28:
+		// LEA
+		// lea <register> <displacement (16 or 32 bit)>
+		// Loads the address of the next instruction plus the displacement
+		toregister := Mapper(ProgramCounter + 1)
+		loc, next := relative(ProgramCounter + 2, 0x04)
+		SetRegister(uint32(toregister), loc)
+		SetRegister(0x001a, next)
+		Log("lea " + getRegisterName(toregister) + ", " + fmt.Sprintf("0x%08x", loc))
+		stall(4)
+	case 0x29:
+		// CALL
+		// call <mode> <target (immediate, register or relative)>
+		// Pushes the address of the next instruction, then jumps
+		mode := Mapper(ProgramCounter + 1)
+		var loc uint32 = 0
+		var next uint32 = 0
+		if mode == 0x01 {
+			if types.Bits32 == false {
+				loc = uint32(uint16(Mapper(ProgramCounter + 2)) << 8 | uint16(Mapper(ProgramCounter + 3)))
+				next = ProgramCounter + 4
+			} else {
+				loc = uint32(Mapper(ProgramCounter + 2)) << 24 | uint32(Mapper(ProgramCounter + 3)) << 16 | uint32(Mapper(ProgramCounter + 4)) << 8 | uint32(Mapper(ProgramCounter + 5))
+				next = ProgramCounter + 6
+			}
+			Log("call " + fmt.Sprintf("0x%08x", loc))
+		} else if mode == 0x02 {
+			frm := uint32(Mapper(ProgramCounter + 2))
+			loc = GetRegister(frm)
+			next = ProgramCounter + 3
+			Log("call " + getRegisterName(frm))
+		} else if mode == 0x03 || mode == 0x04 {
+			loc, next = relative(ProgramCounter + 2, mode)
+			Log("call " + fmt.Sprintf("0x%08x", loc) + " (relative)")
+		} else {
+			if illegal(ProgramCounter, op) == true {
+				return false
+			}
+			return true
+		}
+		pushStack(next)
+		SetRegister(0x001a, loc)
+		stall(10)
+	case 0x2a:
+		// RET
+		// Pops the return address pushed by CALL
+		loc := popStack()
+		SetRegister(0x001a, loc)
+		Log("ret " + fmt.Sprintf("0x%08x", loc))
+		stall(10)
+	case 0x2b:
+		// LDB
+		// ldb <destination register> <base register> <offset>
+		// Loads 8 bits, zero-extended
+		register, addr, next := memoryOperands(ProgramCounter)
+		SetRegister(register, readMemory(addr, 1))
+		SetRegister(0x001a, next)
+		Log("ldb " + getRegisterName(register) + ", " + fmt.Sprintf("0x%08x", addr))
+		stall(100)
+	case 0x2c:
+		// LDW
+		// ldw <destination register> <base register> <offset>
+		// Loads 16 bits, zero-extended
+		register, addr, next := memoryOperands(ProgramCounter)
+		SetRegister(register, readMemory(addr, 2))
+		SetRegister(0x001a, next)
+		Log("ldw " + getRegisterName(register) + ", " + fmt.Sprintf("0x%08x", addr))
+		stall(100)
+	case 0x2d:
+		// LDD
+		// ldd <destination register> <base register> <offset>
+		// Loads 32 bits, zero-extended
+		register, addr, next := memoryOperands(ProgramCounter)
+		SetRegister(register, readMemory(addr, 4))
+		SetRegister(0x001a, next)
+		Log("ldd " + getRegisterName(register) + ", " + fmt.Sprintf("0x%08x", addr))
+		stall(100)
+	case 0x2e:
+		// STB
+		// stb <value register> <base register> <offset>
+		// Stores the low 8 bits
+		register, addr, next := memoryOperands(ProgramCounter)
+		writeMemory(addr, 1, GetRegister(register))
+		SetRegister(0x001a, next)
+		Log("stb " + getRegisterName(register) + ", " + fmt.Sprintf("0x%08x", addr))
+		stall(100)
+	case 0x2f:
+		// STW
+		// stw <value register> <base register> <offset>
+		// Stores the low 16 bits
+		register, addr, next := memoryOperands(ProgramCounter)
+		writeMemory(addr, 2, GetRegister(register))
+		SetRegister(0x001a, next)
+		Log("stw " + getRegisterName(register) + ", " + fmt.Sprintf("0x%08x", addr))
+		stall(100)
+	case 0x30:
+		// STD
+		// std <value register> <base register> <offset>
+		// Stores the low 32 bits
+		register, addr, next := memoryOperands(ProgramCounter)
+		writeMemory(addr, 4, GetRegister(register))
+		SetRegister(0x001a, next)
+		Log("std " + getRegisterName(register) + ", " + fmt.Sprintf("0x%08x", addr))
+		stall(100)
+	case 0x31:
+		// CAS
+		// cas <expected register> <addr (register)> <new value register>
+		// Stores the new value if memory holds the expected value, the expected
+		// register always receives the old value
+		expected := uint32(Mapper(ProgramCounter + 1))
+		addr := GetRegister(uint32(Mapper(ProgramCounter + 2)))
+		value := uint32(Mapper(ProgramCounter + 3))
+		old := readMemory(addr, width() / 8)
+		if old == GetRegister(expected) {
+			writeMemory(addr, width() / 8, GetRegister(value))
+		}
+		SetRegister(expected, old)
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("cas " + getRegisterName(expected) + ", " + fmt.Sprintf("0x%08x", addr) + ", " + getRegisterName(value))
+		stall(120)
+	case 0x32:
+		// XADD
+		// xadd <destination register> <addr (register)> <addend register>
+		// Adds to memory, the destination receives the old value
+		toregister := uint32(Mapper(ProgramCounter + 1))
+		addr := GetRegister(uint32(Mapper(ProgramCounter + 2)))
+		addend := uint32(Mapper(ProgramCounter + 3))
+		old := readMemory(addr, width() / 8)
+		writeMemory(addr, width() / 8, old + GetRegister(addend))
+		SetRegister(toregister, old)
+		SetRegister(0x001a, ProgramCounter + 4)
+		Log("xadd " + getRegisterName(toregister) + ", " + fmt.Sprintf("0x%08x", addr) + ", " + getRegisterName(addend))
+		stall(120)
+	case 0x33:
+		// CID
+		// cid <register>
+		toregister := uint32(Mapper(ProgramCounter + 1))
+		SetRegister(toregister, Current.ID)
+		SetRegister(0x001a, ProgramCounter + 2)
+		Log("cid " + getRegisterName(toregister))
+		stall(1)
+	case 0x34:
+		// IPI
+		// ipi <core register> <message register>
+		// Queues an inter-processor interrupt, unknown cores are ignored
+		target := GetRegister(uint32(Mapper(ProgramCounter + 1)))
+		message := GetRegister(uint32(Mapper(ProgramCounter + 2)))
+		if target < uint32(len(Cores)) {
+			Cores[target].Pending = append(Cores[target].Pending, Interrupt{Message: message, IPI: true})
+		}
+		SetRegister(0x001a, ProgramCounter + 3)
+		Log("ipi " + fmt.Sprintf("%d", target) + ", " + fmt.Sprintf("0x%08x", message))
+		stall(20)
+	case 0x35:
+		// IVEC
+		// ivec <addr (register)>
+		// Sets the IPI handler of this core, 0 removes it
+		Current.Handler = GetRegister(uint32(Mapper(ProgramCounter + 1)))
+		SetRegister(0x001a, ProgramCounter + 2)
+		Log("ivec " + fmt.Sprintf("0x%08x", Current.Handler))
+		stall(1)
+	default:
+		if illegal(ProgramCounter, op) == true {
+			return false
+		}
+	}
+
+	return true
+}
+
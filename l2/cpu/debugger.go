@@ -0,0 +1,188 @@
+package cpu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Debugger
+// With --debug the machine stops before every instruction and reads commands
+// from stdin. It also stops at breakpoints, after a step that writes a watched
+// address, on an illegal instruction and once every core is halted.
+var Breakpoints = map[uint32]bool {}
+var Watchpoints = map[uint32]bool {}
+// Why the debugger should stop before the next instruction
+var Trap string = ""
+var stepping bool = true
+var stdin = bufio.NewReader(os.Stdin)
+
+const debugHelp = `s, step               run one instruction
+c, continue           run until a breakpoint or watchpoint
+sb, step-back         undo the last instruction
+rc, reverse-continue  undo instructions until a breakpoint or watchpoint
+b, break <addr>       stop when PC reaches addr
+w, watch <addr>       stop after a write to addr
+d, delete             remove every breakpoint and watchpoint
+r, regs               show the registers of the current core
+x <addr> [n]          show n bytes of memory
+lw, last-write <addr> show which instruction last wrote addr
+q, quit               stop the machine`
+
+// Called before every step, returns false if the machine should stop
+func debugger() bool {
+	if Breakpoints[GetRegister(0x001a)] == true && Trap == "" {
+		Trap = "breakpoint"
+	}
+	if stepping == false && Trap == "" {
+		return true
+	}
+	return prompt()
+}
+
+// Checks the watchpoints against a step that just ran
+func watch(done *undo) {
+	if done == nil {
+		return
+	}
+	for address := range Watchpoints {
+		if done.wrote(address) == true {
+			Trap = fmt.Sprintf("watchpoint 0x%08x written at 0x%08x", address, done.PC)
+		}
+	}
+}
+
+func where() string {
+	pc := GetRegister(0x001a)
+	return fmt.Sprintf("core %d, pc 0x%08x, opcode 0x%02x, %d instructions", Current.ID, pc, Mapper(pc), Instructions)
+}
+
+func prompt() bool {
+	if Trap != "" {
+		fmt.Println("\033[31m" + Trap + "\033[0m")
+		Trap = ""
+	}
+	fmt.Println(where())
+	for {
+		fmt.Print("(ldb) ")
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		words := strings.Fields(line)
+		command := ""
+		if len(words) > 0 {
+			command = words[0]
+		}
+		switch command {
+		case "", "s", "step":
+			stepping = true
+			return true
+		case "c", "continue":
+			stepping = false
+			return true
+		case "sb", "step-back":
+			if StepBack() == false {
+				fmt.Println("no more history")
+			}
+			fmt.Println(where())
+		case "rc", "reverse-continue":
+			reverseContinue()
+			fmt.Println(where())
+		case "b", "break", "w", "watch":
+			if len(words) < 2 {
+				fmt.Println("missing address")
+				continue
+			}
+			address, err := strconv.ParseUint(words[1], 0, 32)
+			if err != nil {
+				fmt.Println("invalid address")
+				continue
+			}
+			if command == "b" || command == "break" {
+				Breakpoints[uint32(address)] = true
+			} else {
+				Watchpoints[uint32(address)] = true
+			}
+		case "d", "delete":
+			Breakpoints = map[uint32]bool {}
+			Watchpoints = map[uint32]bool {}
+		case "r", "regs":
+			line := ""
+			for _, register := range Registers {
+				line += fmt.Sprintf("%s=0x%08x ", register.Name, register.Value)
+			}
+			fmt.Println(line)
+		case "x":
+			if len(words) < 2 {
+				fmt.Println("missing address")
+				continue
+			}
+			address, err := strconv.ParseUint(words[1], 0, 32)
+			if err != nil {
+				fmt.Println("invalid address")
+				continue
+			}
+			count := uint64(16)
+			if len(words) > 2 {
+				count, _ = strconv.ParseUint(words[2], 0, 32)
+			}
+			text := fmt.Sprintf("0x%08x:", address)
+			for i := uint64(0); i < count; i++ {
+				text += fmt.Sprintf(" %02x", Mapper(uint32(address + i)))
+			}
+			fmt.Println(text)
+		case "lw", "last-write":
+			if len(words) < 2 {
+				fmt.Println("missing address")
+				continue
+			}
+			address, err := strconv.ParseUint(words[1], 0, 32)
+			if err != nil {
+				fmt.Println("invalid address")
+				continue
+			}
+			pc, core, count, found := LastWrite(uint32(address))
+			if found == false {
+				fmt.Println("not written within the history")
+				continue
+			}
+			fmt.Printf("written by core %d at pc 0x%08x, instruction %d\n", core, pc, count)
+		case "q", "quit":
+			return false
+		case "h", "help":
+			fmt.Println(debugHelp)
+		default:
+			fmt.Println("unknown command '" + command + "', try help")
+		}
+	}
+}
+
+// Undoes steps until the PC is at a breakpoint or the undone step wrote a
+// watched address
+func reverseContinue() {
+	for len(history) > 0 {
+		last := history[len(history) - 1]
+		for address := range Watchpoints {
+			if last.wrote(address) == true {
+				StepBack()
+				fmt.Println("\033[31m" + fmt.Sprintf("watchpoint 0x%08x written at 0x%08x", address, last.PC) + "\033[0m")
+				return
+			}
+		}
+		StepBack()
+		if Breakpoints[GetRegister(0x001a)] == true {
+			fmt.Println("\033[31mbreakpoint\033[0m")
+			return
+		}
+	}
+	fmt.Println("reached the start of the history")
+}
+
+// Used when nothing is runnable, since the next step never comes
+func debugIdle() bool {
+	Trap = "every core is halted"
+	return prompt()
+}
@@ -0,0 +1,145 @@
+package cpu
+
+import (
+	"luna_l2/types"
+)
+
+// Reverse execution
+// While History is on, every step of the machine keeps the old values of the
+// registers and memory it changes, so the debugger can undo steps. Once
+// HistorySize steps are kept the oldest ones are dropped. Devices (the
+// screen, audio and the DMA controller) are not rewound, but memory written by
+// DMA or the BIOS and registers written by the BIOS are.
+type registerChange struct {
+	Address uint32
+	Old uint32
+}
+
+type memoryChange struct {
+	Address uint32
+	Old byte
+}
+
+// What one step of the machine changed
+type undo struct {
+	Core *Core
+	PC uint32
+	Cycles uint64
+	VBlank uint64
+	Instructions uint64
+	Bits32 bool
+	Halted bool
+	Handler uint32
+	// Pending interrupts of every core, IPI can queue one on another core
+	Pending [][]Interrupt
+	Registers []registerChange
+	Memory []memoryChange
+}
+
+var History bool = false
+var HistorySize int = 10000
+var history = []*undo {}
+// Step being recorded, nil between steps
+var entry *undo
+
+func begin() {
+	if History == false {
+		return
+	}
+	entry = &undo{
+		Core: Current,
+		PC: GetRegister(0x001a),
+		Cycles: Cycles,
+		VBlank: lastVBlank,
+		Instructions: Instructions,
+		Bits32: types.Bits32,
+		Halted: Current.Halted,
+		Handler: Current.Handler,
+	}
+	for _, core := range Cores {
+		entry.Pending = append(entry.Pending, core.Pending)
+	}
+}
+
+func end() *undo {
+	done := entry
+	if done == nil {
+		return nil
+	}
+	entry = nil
+	history = append(history, done)
+	if len(history) > HistorySize {
+		history[0] = nil
+		history = history[1:]
+	}
+	return done
+}
+
+// Keeps the old value of a byte of memory before it is written
+func remember(address uint32, old byte) {
+	if entry != nil {
+		entry.Memory = append(entry.Memory, memoryChange{Address: address, Old: old})
+	}
+}
+
+// Keeps the old value of a register before it is written
+func rememberRegister(address uint32, old uint32) {
+	if entry != nil {
+		entry.Registers = append(entry.Registers, registerChange{Address: address, Old: old})
+	}
+}
+
+// Undoes the last step, returns false if the history is empty
+func StepBack() bool {
+	if len(history) == 0 {
+		return false
+	}
+	last := history[len(history) - 1]
+	history[len(history) - 1] = nil
+	history = history[:len(history) - 1]
+
+	for i := len(last.Memory) - 1; i >= 0; i-- {
+		Memory[last.Memory[i].Address] = last.Memory[i].Old
+	}
+	switchCore(last.Core)
+	for i := len(last.Registers) - 1; i >= 0; i-- {
+		for j := range Registers {
+			if Registers[j].Address == last.Registers[i].Address {
+				Registers[j].Value = last.Registers[i].Old
+			}
+		}
+	}
+	types.Bits32 = last.Bits32
+	Current.Halted = last.Halted
+	Current.Handler = last.Handler
+	for i, core := range Cores {
+		core.Pending = last.Pending[i]
+	}
+	Cycles = last.Cycles
+	lastVBlank = last.VBlank
+	Instructions = last.Instructions
+	return true
+}
+
+// Finds the most recent step in the history that wrote an address, returns
+// its PC, core and instruction count
+func LastWrite(address uint32) (uint32, uint32, uint64, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		for _, change := range history[i].Memory {
+			if change.Address == address {
+				return history[i].PC, history[i].Core.ID, history[i].Instructions, true
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// Whether a step wrote an address
+func (s *undo) wrote(address uint32) bool {
+	for _, change := range s.Memory {
+		if change.Address == address {
+			return true
+		}
+	}
+	return false
+}
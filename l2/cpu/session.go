@@ -0,0 +1,162 @@
+package cpu
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"luna_l2/bios"
+)
+
+// Key input
+// The frontend queues keys with Key and the CPU delivers them between
+// instructions (or to a waiting interrupt 6), so every key arrives at a known
+// point of the run. A session log records that point and replay delivers the
+// keys at the same point again, which makes the rerun identical as long as the
+// program, flags and seed are the same.
+type KeyEvent struct {
+	Cycle uint64
+	// Breaks ties between instructions that take no cycles
+	Instruction uint64
+	Code uint32
+	// Taken by interrupt 6 rather than delivered between instructions
+	Wait bool
+}
+
+var keyLock sync.Mutex
+var keyQueue = []uint32 {}
+// Keys still to be replayed, live keys are ignored until they run out. Replayed
+// keys are recorded again, so a replay can be extended into a new session.
+var Replay = []KeyEvent {}
+var Recording *os.File
+
+// Queues a key press from the frontend
+func Key(code uint32) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	if len(Replay) > 0 {
+		return
+	}
+	keyQueue = append(keyQueue, code)
+}
+
+// Takes the next key that is due, from the replay or the live queue
+func nextKey(waiting bool) (uint32, bool) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	if len(Replay) > 0 {
+		event := Replay[0]
+		// A waiting program gets the next key right away, it was waiting at
+		// the same point when the session was recorded
+		if waiting == true || (event.Wait == false && (Cycles > event.Cycle || (Cycles == event.Cycle && Instructions >= event.Instruction))) {
+			Replay = Replay[1:]
+			record(event.Code, waiting)
+			return event.Code, true
+		}
+		return 0, false
+	}
+	if len(keyQueue) > 0 {
+		code := keyQueue[0]
+		keyQueue = keyQueue[1:]
+		record(code, waiting)
+		return code, true
+	}
+	return 0, false
+}
+
+func record(code uint32, waiting bool) {
+	if Recording != nil {
+		kind := "key"
+		if waiting == true {
+			kind = "wait"
+		}
+		fmt.Fprintf(Recording, "%s %d %d %d\n", kind, Cycles, Instructions, code)
+	}
+}
+
+// Delivers the keys that are due through the key event interrupt
+func deliverKeys() {
+	for {
+		code, ok := nextKey(false)
+		if ok == false {
+			return
+		}
+		Log("key " + fmt.Sprintf("0x%02x", code))
+		SetRegister(0x001b, code)
+		bios.IntHandler(bios.KeyInterruptCode)
+	}
+}
+
+// Interrupt 6, blocks until a key arrives. A headless machine gets 0 once the
+// replay runs out, since no key can arrive.
+func waitKey() (uint32, bool) {
+	for {
+		if code, ok := nextKey(true); ok == true {
+			Log("key " + fmt.Sprintf("0x%02x", code))
+			return code, true
+		}
+		if Headless == true || TimedOut.Load() == true {
+			return 0, false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Starts a session log, the seed goes first so a replay schedules the cores
+// the same way
+func Record(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	Recording = file
+	fmt.Fprintf(Recording, "# luna-l2 session\nseed %d\n", Seed)
+	return nil
+}
+
+// Reads a session log into Replay and restores its seed
+func LoadReplay(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	events := []KeyEvent {}
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "seed" && len(fields) == 2 {
+			seed, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid seed", line)
+			}
+			Seed = seed
+		} else if (fields[0] == "key" || fields[0] == "wait") && len(fields) == 4 {
+			cycle, err1 := strconv.ParseUint(fields[1], 10, 64)
+			instruction, err2 := strconv.ParseUint(fields[2], 10, 64)
+			code, err3 := strconv.ParseUint(fields[3], 10, 32)
+			if err1 != nil || err2 != nil || err3 != nil {
+				return fmt.Errorf("line %d: invalid key event", line)
+			}
+			events = append(events, KeyEvent{Cycle: cycle, Instruction: instruction, Code: uint32(code), Wait: fields[0] == "wait"})
+		} else {
+			return fmt.Errorf("line %d: unknown entry '%s'", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	keyLock.Lock()
+	Replay = events
+	keyLock.Unlock()
+	return nil
+}
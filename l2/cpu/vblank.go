@@ -0,0 +1,41 @@
+package cpu
+
+import (
+	"luna_l2/video"
+)
+
+// Vertical blank
+// Every FrameCycles emulated cycles the video device finishes a frame: a
+// pending flip is shown, the window is told to repaint and the vblank
+// interrupt is raised if the program asked for it. Frames follow emulated
+// time, so they fall on the same instructions in every run.
+var FrameCycles uint64 = 1158000 / 60
+// Cycle of the last vertical blank
+var lastVBlank uint64 = 0
+
+// Called as the clock advances
+func vblank() {
+	if FrameCycles == 0 || Cycles < lastVBlank + FrameCycles {
+		return
+	}
+	// A long stall can skip frames, only the last one is shown
+	lastVBlank = Cycles - (Cycles - lastVBlank) % FrameCycles
+	video.VBlank()
+	if vblankHandled() == true {
+		Raise(video.VBlankCore, video.VBlankMessage)
+	}
+}
+
+// Whether the vblank interrupt is on and its core has a handler, an interrupt
+// to a core without one would start it like a startup IPI
+func vblankHandled() bool {
+	return video.VBlankMessage != 0 && video.VBlankCore < uint32(len(Cores)) && Cores[video.VBlankCore].Handler != 0
+}
+
+// Interrupts 42 and 44, holds up every core until the next vertical blank
+func waitVBlank() {
+	if FrameCycles == 0 {
+		return
+	}
+	stall(int64(lastVBlank + FrameCycles - Cycles))
+}
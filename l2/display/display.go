@@ -0,0 +1,105 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// How the window shows a frame. By default the frame is as large as the window
+// allows, centred, with square pixels.
+// Scales by whole numbers only, so every pixel has the same size
+var IntegerScale bool = false
+// Shows every mode at 4:3 like a monitor would, which makes the pixels of the
+// 320x200 and 640x400 frames taller than they are wide
+var Aspect bool = false
+// Draws the frame with scanlines and soft edges like a CRT
+var CRT bool = false
+// Colour around the frame
+var Border color.NRGBA = color.NRGBA{0, 0, 0, 255}
+
+// Where a frame of a size goes in a window of a size
+func Place(frame image.Point, window image.Point) image.Rectangle {
+	if frame.X <= 0 || frame.Y <= 0 {
+		return image.Rectangle{}
+	}
+	// Scale is in window pixels per frame column
+	height := float64(frame.Y)
+	if Aspect == true {
+		height = float64(frame.X) * 3 / 4
+	}
+	scale := min(float64(window.X) / float64(frame.X), float64(window.Y) / height)
+	if IntegerScale == true {
+		scale = max(float64(int(scale)), 1)
+	}
+	size := image.Pt(int(float64(frame.X) * scale), int(height * scale))
+	corner := window.Sub(size).Div(2)
+	return image.Rectangle{corner, corner.Add(size)}
+}
+
+// Draws a frame for the CRT look: every pixel is 3x3 (2x2 for frames over 240
+// rows), the bottom row of each is a scanline at half brightness, and the edges
+// of a pixel blend into its neighbours. The window then scales it smoothly.
+func Filter(frame *image.Paletted) *image.RGBA {
+	factor := 3
+	if frame.Rect.Dy() > 240 {
+		factor = 2
+	}
+	width, height := frame.Rect.Dx(), frame.Rect.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, width * factor, height * factor))
+	rgb := [256][3]int {}
+	for i, c := range frame.Palette {
+		r, g, b, _ := c.RGBA()
+		rgb[i] = [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+	}
+	for y := 0; y < height; y++ {
+		row := frame.Pix[y * frame.Stride:]
+		for x := 0; x < width; x++ {
+			pixel := rgb[row[x]]
+			left, right := pixel, pixel
+			if x > 0 {
+				left = rgb[row[x - 1]]
+			}
+			if x < width - 1 {
+				right = rgb[row[x + 1]]
+			}
+			for sx := 0; sx < factor; sx++ {
+				c := pixel
+				if sx == 0 {
+					c = blend(pixel, left)
+				} else if sx == factor - 1 {
+					c = blend(pixel, right)
+				}
+				for sy := 0; sy < factor; sy++ {
+					shade := 2
+					if sy == factor - 1 {
+						shade = 1
+					}
+					at := out.PixOffset(x * factor + sx, y * factor + sy)
+					out.Pix[at] = uint8(c[0] * shade / 2)
+					out.Pix[at + 1] = uint8(c[1] * shade / 2)
+					out.Pix[at + 2] = uint8(c[2] * shade / 2)
+					out.Pix[at + 3] = 255
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Three quarters of a colour and a quarter of another
+func blend(a [3]int, b [3]int) [3]int {
+	return [3]int{(a[0] * 3 + b[0]) / 4, (a[1] * 3 + b[1]) / 4, (a[2] * 3 + b[2]) / 4}
+}
+
+// Reads a colour written as RRGGBB in hex, with or without a leading #
+func ParseColour(text string) (color.NRGBA, error) {
+	text = strings.TrimPrefix(text, "#")
+	value, err := strconv.ParseUint(text, 16, 32)
+	if err != nil || len(text) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid colour '%s', expected RRGGBB", text)
+	}
+	return color.NRGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 255}, nil
+}
@@ -0,0 +1,59 @@
+package display
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPlace(t *testing.T) {
+	// The CRT filter and border do not move the frame, every option is put
+	// back for the other tests
+	integer, aspect, crt, border := IntegerScale, Aspect, CRT, Border
+	defer func() {
+		IntegerScale, Aspect, CRT, Border = integer, aspect, crt, border
+	}()
+	CRT, Border = true, color.NRGBA{1, 2, 3, 255}
+	frame, window := image.Pt(320, 200), image.Pt(1000, 700)
+	for _, test := range []struct {
+		integer, aspect bool
+		want image.Rectangle
+	}{
+		{false, false, image.Rect(0, 37, 1000, 662)},
+		{true, false, image.Rect(20, 50, 980, 650)},
+		{true, true, image.Rect(180, 110, 820, 590)},
+	} {
+		IntegerScale, Aspect = test.integer, test.aspect
+		if got := Place(frame, window); got != test.want {
+			t.Errorf("integer %v, aspect %v: place = %v, want %v", test.integer, test.aspect, got, test.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	// Every pixel is 3x3 with a scanline at half brightness below it
+	img := image.NewPaletted(image.Rect(0, 0, 2, 1), color.Palette{color.NRGBA{200, 0, 0, 255}, color.NRGBA{0, 0, 200, 255}})
+	img.Pix[1] = 1
+	crt := Filter(img)
+	if crt.Rect != image.Rect(0, 0, 6, 3) {
+		t.Fatalf("CRT size = %v", crt.Rect)
+	}
+	if got := crt.RGBAAt(1, 0); got != (color.RGBA{200, 0, 0, 255}) {
+		t.Errorf("CRT pixel = %v", got)
+	}
+	if got := crt.RGBAAt(1, 2); got != (color.RGBA{100, 0, 0, 255}) {
+		t.Errorf("CRT scanline = %v", got)
+	}
+	if got := crt.RGBAAt(2, 0); got != (color.RGBA{150, 0, 50, 255}) {
+		t.Errorf("CRT edge = %v", got)
+	}
+}
+
+func TestParseColour(t *testing.T) {
+	if border, err := ParseColour("#102030"); err != nil || border != (color.NRGBA{0x10, 0x20, 0x30, 255}) {
+		t.Errorf("border = %v, %v", border, err)
+	}
+	if _, err := ParseColour("12345"); err == nil {
+		t.Errorf("short colour was accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package dma
+
+import (
+	"luna_l2/video"
+	"luna_l2/audio"
+	"luna_l2/types"
+)
+
+// Address spaces a transfer can read from or write to
+const (
+	SpaceMemory uint32 = 0
+	SpaceVideo uint32 = 1
+	SpaceAudio uint32 = 2
+)
+
+// Bytes moved per emulated cycle
+const Rate = 4
+
+type Transfer struct {
+	Source uint32
+	Destination uint32
+	Length uint32
+	From uint32
+	To uint32
+	Fill bool
+	Value byte
+	Message uint32
+	Core uint32
+	Done uint32
+}
+
+var Memory *[0x70000000]byte
+// Called with the old value before a transfer writes main memory
+var Written func(address uint32, old byte)
+var Active *Transfer
+// Raises the completion interrupt on a core
+var Interrupt func(core uint32, message uint32)
+
+func space(id uint32) []byte {
+	switch id {
+	case SpaceMemory:
+		return Memory[:types.MemorySize]
+	case SpaceVideo:
+		return video.VRAM()
+	case SpaceAudio:
+		return audio.MemoryAudio[:]
+	}
+	return nil
+}
+
+// Starts a transfer, returns false if one is already running or a space is invalid
+func Start(transfer Transfer) bool {
+	if Active != nil {
+		return false
+	}
+	if space(transfer.To) == nil || (transfer.Fill == false && space(transfer.From) == nil) {
+		return false
+	}
+	transfer.Done = 0
+	Active = &transfer
+	return true
+}
+
+func Remaining() uint32 {
+	if Active == nil {
+		return 0
+	}
+	return Active.Length - Active.Done
+}
+
+// Advances the running transfer by a number of emulated cycles.
+// Bytes are copied forward one at a time; reads past the end of a space give 0
+// and writes past the end are dropped.
+func Tick(cycles int64) {
+	if Active == nil || cycles <= 0 {
+		return
+	}
+	transfer := Active
+	from := space(transfer.From)
+	to := space(transfer.To)
+
+	budget := uint64(cycles) * Rate
+	for ; budget > 0 && transfer.Done < transfer.Length; budget-- {
+		value := transfer.Value
+		if transfer.Fill == false {
+			address := uint64(transfer.Source) + uint64(transfer.Done)
+			if address < uint64(len(from)) {
+				value = from[address]
+			} else {
+				value = 0
+			}
+		}
+		address := uint64(transfer.Destination) + uint64(transfer.Done)
+		if address < uint64(len(to)) {
+			if transfer.To == SpaceMemory && Written != nil {
+				Written(uint32(address), to[address])
+			}
+			to[address] = value
+		}
+		transfer.Done++
+	}
+
+	if transfer.Done >= transfer.Length {
+		Active = nil
+		if transfer.Message != 0 && Interrupt != nil {
+			Interrupt(transfer.Core, transfer.Message)
+		}
+	}
+}
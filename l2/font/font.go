@@ -1,6 +1,7 @@
 package font
 
-var Font = [128][8]byte {
+// Glyphs for the 256 characters, bit 0 of each row is the leftmost pixel
+var Font = [256][8]byte {
     { 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},   // U+0000 (nul)
     { 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},   // U+0001
     { 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},   // U+0002
@@ -129,4 +130,134 @@ var Font = [128][8]byte {
     { 0x07, 0x0C, 0x0C, 0x38, 0x0C, 0x0C, 0x07, 0x00},   // U+007D (})
     { 0x6E, 0x3B, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},   // U+007E (~)
     { 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},   // U+007F
+    // Code page 437 from here on, each glyph is commented with the
+    // character it draws
+    { 0x3C, 0x66, 0x03, 0x03, 0x03, 0x66, 0x3C, 0x18},   // U+00C7 (Ç)
+    { 0x33, 0x00, 0x33, 0x33, 0x33, 0x33, 0x6E, 0x00},   // U+00FC (ü)
+    { 0x18, 0x0C, 0x1E, 0x33, 0x3F, 0x03, 0x1E, 0x00},   // U+00E9 (é)
+    { 0x0C, 0x12, 0x1E, 0x30, 0x3E, 0x33, 0x6E, 0x00},   // U+00E2 (â)
+    { 0x33, 0x00, 0x1E, 0x30, 0x3E, 0x33, 0x6E, 0x00},   // U+00E4 (ä)
+    { 0x06, 0x0C, 0x1E, 0x30, 0x3E, 0x33, 0x6E, 0x00},   // U+00E0 (à)
+    { 0x0C, 0x12, 0x0C, 0x1E, 0x30, 0x3E, 0x33, 0x6E},   // U+00E5 (å)
+    { 0x00, 0x00, 0x1E, 0x33, 0x03, 0x33, 0x1E, 0x0C},   // U+00E7 (ç)
+    { 0x0C, 0x12, 0x1E, 0x33, 0x3F, 0x03, 0x1E, 0x00},   // U+00EA (ê)
+    { 0x33, 0x00, 0x1E, 0x33, 0x3F, 0x03, 0x1E, 0x00},   // U+00EB (ë)
+    { 0x06, 0x0C, 0x1E, 0x33, 0x3F, 0x03, 0x1E, 0x00},   // U+00E8 (è)
+    { 0x33, 0x00, 0x0E, 0x0C, 0x0C, 0x0C, 0x1E, 0x00},   // U+00EF (ï)
+    { 0x0C, 0x12, 0x0E, 0x0C, 0x0C, 0x0C, 0x1E, 0x00},   // U+00EE (î)
+    { 0x06, 0x0C, 0x0E, 0x0C, 0x0C, 0x0C, 0x1E, 0x00},   // U+00EC (ì)
+    { 0x33, 0x0C, 0x1E, 0x33, 0x3F, 0x33, 0x33, 0x00},   // U+00C4 (Ä)
+    { 0x0C, 0x12, 0x0C, 0x1E, 0x33, 0x3F, 0x33, 0x00},   // U+00C5 (Å)
+    { 0x18, 0x7F, 0x46, 0x1E, 0x16, 0x46, 0x7F, 0x00},   // U+00C9 (É)
+    { 0x00, 0x00, 0x7E, 0x90, 0xFE, 0x11, 0xFE, 0x00},   // U+00E6 (æ)
+    { 0x7C, 0x36, 0x33, 0x7F, 0x33, 0x33, 0x73, 0x00},   // U+00C6 (Æ)
+    { 0x0C, 0x12, 0x1E, 0x33, 0x33, 0x33, 0x1E, 0x00},   // U+00F4 (ô)
+    { 0x33, 0x00, 0x1E, 0x33, 0x33, 0x33, 0x1E, 0x00},   // U+00F6 (ö)
+    { 0x06, 0x0C, 0x1E, 0x33, 0x33, 0x33, 0x1E, 0x00},   // U+00F2 (ò)
+    { 0x0C, 0x12, 0x33, 0x33, 0x33, 0x33, 0x6E, 0x00},   // U+00FB (û)
+    { 0x06, 0x0C, 0x33, 0x33, 0x33, 0x33, 0x6E, 0x00},   // U+00F9 (ù)
+    { 0x33, 0x00, 0x33, 0x33, 0x33, 0x3E, 0x30, 0x1F},   // U+00FF (ÿ)
+    { 0x63, 0x1C, 0x36, 0x63, 0x63, 0x36, 0x1C, 0x00},   // U+00D6 (Ö)
+    { 0x33, 0x00, 0x33, 0x33, 0x33, 0x33, 0x3F, 0x00},   // U+00DC (Ü)
+    { 0x18, 0x18, 0x7E, 0x03, 0x03, 0x7E, 0x18, 0x18},   // U+00A2 (¢)
+    { 0x1C, 0x36, 0x26, 0x0F, 0x06, 0x67, 0x3F, 0x00},   // U+00A3 (£)
+    { 0x33, 0x33, 0x1E, 0x3F, 0x0C, 0x3F, 0x0C, 0x0C},   // U+00A5 (¥)
+    { 0x1F, 0x33, 0x33, 0x5F, 0x63, 0xF3, 0x63, 0xE3},   // U+20A7 (₧)
+    { 0x70, 0xD8, 0x18, 0x3C, 0x18, 0x18, 0x1B, 0x0E},   // U+0192 (ƒ)
+    { 0x18, 0x0C, 0x1E, 0x30, 0x3E, 0x33, 0x6E, 0x00},   // U+00E1 (á)
+    { 0x18, 0x0C, 0x0E, 0x0C, 0x0C, 0x0C, 0x1E, 0x00},   // U+00ED (í)
+    { 0x18, 0x0C, 0x1E, 0x33, 0x33, 0x33, 0x1E, 0x00},   // U+00F3 (ó)
+    { 0x18, 0x0C, 0x33, 0x33, 0x33, 0x33, 0x6E, 0x00},   // U+00FA (ú)
+    { 0x16, 0x0D, 0x1F, 0x33, 0x33, 0x33, 0x33, 0x00},   // U+00F1 (ñ)
+    { 0x3F, 0x00, 0x33, 0x37, 0x3F, 0x3B, 0x33, 0x00},   // U+00D1 (Ñ)
+    { 0x3C, 0x36, 0x36, 0x7C, 0x00, 0x7E, 0x00, 0x00},   // U+00AA (ª)
+    { 0x1C, 0x36, 0x36, 0x1C, 0x00, 0x3E, 0x00, 0x00},   // U+00BA (º)
+    { 0x0C, 0x00, 0x0C, 0x18, 0x30, 0x33, 0x1E, 0x00},   // U+00BF (¿)
+    { 0x00, 0x00, 0x3F, 0x03, 0x03, 0x00, 0x00, 0x00},   // U+2310 (⌐)
+    { 0x00, 0x00, 0x3F, 0x30, 0x30, 0x00, 0x00, 0x00},   // U+00AC (¬)
+    { 0x41, 0x21, 0x11, 0x68, 0x44, 0x22, 0x11, 0x70},   // U+00BD (½)
+    { 0x41, 0x21, 0x11, 0x28, 0x34, 0x7A, 0x21, 0x00},   // U+00BC (¼)
+    { 0x18, 0x00, 0x18, 0x18, 0x3C, 0x3C, 0x18, 0x00},   // U+00A1 (¡)
+    { 0x00, 0xCC, 0x66, 0x33, 0x66, 0xCC, 0x00, 0x00},   // U+00AB («)
+    { 0x00, 0x33, 0x66, 0xCC, 0x66, 0x33, 0x00, 0x00},   // U+00BB (»)
+    { 0x22, 0x88, 0x22, 0x88, 0x22, 0x88, 0x22, 0x88},   // U+2591 (░)
+    { 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA, 0x55, 0xAA},   // U+2592 (▒)
+    { 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77, 0xDD, 0x77},   // U+2593 (▓)
+    { 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18},   // U+2502 (│)
+    { 0x18, 0x18, 0x18, 0x1F, 0x1F, 0x18, 0x18, 0x18},   // U+2524 (┤)
+    { 0x18, 0x18, 0x1F, 0x18, 0x18, 0x1F, 0x18, 0x18},   // U+2561 (╡)
+    { 0x24, 0x24, 0x24, 0x27, 0x27, 0x24, 0x24, 0x24},   // U+2562 (╢)
+    { 0x00, 0x00, 0x00, 0x3F, 0x3F, 0x24, 0x24, 0x24},   // U+2556 (╖)
+    { 0x00, 0x00, 0x1F, 0x18, 0x18, 0x1F, 0x18, 0x18},   // U+2555 (╕)
+    { 0x24, 0x24, 0x27, 0x20, 0x20, 0x27, 0x24, 0x24},   // U+2563 (╣)
+    { 0x24, 0x24, 0x24, 0x24, 0x24, 0x24, 0x24, 0x24},   // U+2551 (║)
+    { 0x00, 0x00, 0x3F, 0x20, 0x20, 0x27, 0x24, 0x24},   // U+2557 (╗)
+    { 0x24, 0x24, 0x27, 0x20, 0x20, 0x3F, 0x00, 0x00},   // U+255D (╝)
+    { 0x24, 0x24, 0x24, 0x3F, 0x3F, 0x00, 0x00, 0x00},   // U+255C (╜)
+    { 0x18, 0x18, 0x1F, 0x18, 0x18, 0x1F, 0x00, 0x00},   // U+255B (╛)
+    { 0x00, 0x00, 0x00, 0x1F, 0x1F, 0x18, 0x18, 0x18},   // U+2510 (┐)
+    { 0x18, 0x18, 0x18, 0xF8, 0xF8, 0x00, 0x00, 0x00},   // U+2514 (└)
+    { 0x18, 0x18, 0x18, 0xFF, 0xFF, 0x00, 0x00, 0x00},   // U+2534 (┴)
+    { 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x18, 0x18, 0x18},   // U+252C (┬)
+    { 0x18, 0x18, 0x18, 0xF8, 0xF8, 0x18, 0x18, 0x18},   // U+251C (├)
+    { 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x00, 0x00, 0x00},   // U+2500 (─)
+    { 0x18, 0x18, 0x18, 0xFF, 0xFF, 0x18, 0x18, 0x18},   // U+253C (┼)
+    { 0x18, 0x18, 0xF8, 0x18, 0x18, 0xF8, 0x18, 0x18},   // U+255E (╞)
+    { 0x24, 0x24, 0x24, 0xE4, 0xE4, 0x24, 0x24, 0x24},   // U+255F (╟)
+    { 0x24, 0x24, 0xE4, 0x04, 0x04, 0xFC, 0x00, 0x00},   // U+255A (╚)
+    { 0x00, 0x00, 0xFC, 0x04, 0x04, 0xE4, 0x24, 0x24},   // U+2554 (╔)
+    { 0x24, 0x24, 0xE7, 0x00, 0x00, 0xFF, 0x00, 0x00},   // U+2569 (╩)
+    { 0x00, 0x00, 0xFF, 0x00, 0x00, 0xE7, 0x24, 0x24},   // U+2566 (╦)
+    { 0x24, 0x24, 0xE4, 0x04, 0x04, 0xE4, 0x24, 0x24},   // U+2560 (╠)
+    { 0x00, 0x00, 0xFF, 0x00, 0x00, 0xFF, 0x00, 0x00},   // U+2550 (═)
+    { 0x24, 0x24, 0xE7, 0x00, 0x00, 0xE7, 0x24, 0x24},   // U+256C (╬)
+    { 0x18, 0x18, 0xFF, 0x00, 0x00, 0xFF, 0x00, 0x00},   // U+2567 (╧)
+    { 0x24, 0x24, 0x24, 0xFF, 0xFF, 0x00, 0x00, 0x00},   // U+2568 (╨)
+    { 0x00, 0x00, 0xFF, 0x00, 0x00, 0xFF, 0x18, 0x18},   // U+2564 (╤)
+    { 0x00, 0x00, 0x00, 0xFF, 0xFF, 0x24, 0x24, 0x24},   // U+2565 (╥)
+    { 0x24, 0x24, 0x24, 0xFC, 0xFC, 0x00, 0x00, 0x00},   // U+2559 (╙)
+    { 0x18, 0x18, 0xF8, 0x18, 0x18, 0xF8, 0x00, 0x00},   // U+2558 (╘)
+    { 0x00, 0x00, 0xF8, 0x18, 0x18, 0xF8, 0x18, 0x18},   // U+2552 (╒)
+    { 0x00, 0x00, 0x00, 0xFC, 0xFC, 0x24, 0x24, 0x24},   // U+2553 (╓)
+    { 0x24, 0x24, 0x24, 0xFF, 0xFF, 0x24, 0x24, 0x24},   // U+256B (╫)
+    { 0x18, 0x18, 0xFF, 0x18, 0x18, 0xFF, 0x18, 0x18},   // U+256A (╪)
+    { 0x18, 0x18, 0x18, 0x1F, 0x1F, 0x00, 0x00, 0x00},   // U+2518 (┘)
+    { 0x00, 0x00, 0x00, 0xF8, 0xF8, 0x18, 0x18, 0x18},   // U+250C (┌)
+    { 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},   // U+2588 (█)
+    { 0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF},   // U+2584 (▄)
+    { 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F, 0x0F},   // U+258C (▌)
+    { 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0, 0xF0},   // U+2590 (▐)
+    { 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00},   // U+2580 (▀)
+    { 0x00, 0x00, 0x6E, 0x3B, 0x33, 0x3B, 0x6E, 0x00},   // U+03B1 (α)
+    { 0x00, 0x1E, 0x33, 0x1F, 0x33, 0x1F, 0x03, 0x03},   // U+00DF (ß)
+    { 0x3F, 0x33, 0x03, 0x03, 0x03, 0x03, 0x03, 0x00},   // U+0393 (Γ)
+    { 0x00, 0x7F, 0x36, 0x36, 0x36, 0x36, 0x36, 0x00},   // U+03C0 (π)
+    { 0x3F, 0x33, 0x06, 0x0C, 0x06, 0x33, 0x3F, 0x00},   // U+03A3 (Σ)
+    { 0x00, 0x00, 0x7E, 0x1B, 0x1B, 0x1B, 0x0E, 0x00},   // U+03C3 (σ)
+    { 0x00, 0x66, 0x66, 0x66, 0x3E, 0x06, 0x03, 0x00},   // U+00B5 (µ)
+    { 0x00, 0x6E, 0x3B, 0x18, 0x18, 0x18, 0x18, 0x00},   // U+03C4 (τ)
+    { 0x3F, 0x0C, 0x1E, 0x33, 0x33, 0x1E, 0x0C, 0x3F},   // U+03A6 (Φ)
+    { 0x1C, 0x36, 0x63, 0x7F, 0x63, 0x36, 0x1C, 0x00},   // U+0398 (Θ)
+    { 0x1C, 0x36, 0x63, 0x63, 0x36, 0x36, 0x77, 0x00},   // U+03A9 (Ω)
+    { 0x38, 0x0C, 0x18, 0x3E, 0x33, 0x33, 0x1E, 0x00},   // U+03B4 (δ)
+    { 0x00, 0x00, 0x7E, 0xDB, 0xDB, 0x7E, 0x00, 0x00},   // U+221E (∞)
+    { 0x60, 0x30, 0x7E, 0xDB, 0xDB, 0x7E, 0x06, 0x03},   // U+03C6 (φ)
+    { 0x1C, 0x06, 0x03, 0x1F, 0x03, 0x06, 0x1C, 0x00},   // U+03B5 (ε)
+    { 0x00, 0x1E, 0x33, 0x33, 0x33, 0x33, 0x33, 0x00},   // U+2229 (∩)
+    { 0x00, 0x3F, 0x00, 0x3F, 0x00, 0x3F, 0x00, 0x00},   // U+2261 (≡)
+    { 0x0C, 0x0C, 0x3F, 0x0C, 0x0C, 0x00, 0x3F, 0x00},   // U+00B1 (±)
+    { 0x06, 0x0C, 0x18, 0x0C, 0x06, 0x00, 0x3F, 0x00},   // U+2265 (≥)
+    { 0x18, 0x0C, 0x06, 0x0C, 0x18, 0x00, 0x3F, 0x00},   // U+2264 (≤)
+    { 0x70, 0xD8, 0xD8, 0x18, 0x18, 0x18, 0x18, 0x18},   // U+2320 (⌠)
+    { 0x18, 0x18, 0x18, 0x18, 0x1B, 0x1B, 0x0E, 0x00},   // U+2321 (⌡)
+    { 0x0C, 0x0C, 0x00, 0x3F, 0x00, 0x0C, 0x0C, 0x00},   // U+00F7 (÷)
+    { 0x00, 0x6E, 0x3B, 0x00, 0x6E, 0x3B, 0x00, 0x00},   // U+2248 (≈)
+    { 0x1C, 0x36, 0x36, 0x1C, 0x00, 0x00, 0x00, 0x00},   // U+00B0 (°)
+    { 0x00, 0x00, 0x00, 0x18, 0x18, 0x00, 0x00, 0x00},   // U+2219 (∙)
+    { 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00},   // U+00B7 (·)
+    { 0xF0, 0x30, 0x30, 0x37, 0x36, 0x3C, 0x38, 0x00},   // U+221A (√)
+    { 0x1E, 0x36, 0x36, 0x36, 0x00, 0x00, 0x00, 0x00},   // U+207F (ⁿ)
+    { 0x0E, 0x18, 0x0C, 0x06, 0x1E, 0x00, 0x00, 0x00},   // U+00B2 (²)
+    { 0x00, 0x00, 0x3C, 0x3C, 0x3C, 0x3C, 0x00, 0x00},   // U+25A0 (■)
+    { 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},   // U+00A0 (nbsp)
 }
@@ -0,0 +1,309 @@
+package font
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Characters drawn by glyphs 128 to 255, used to place glyphs of fonts that
+// are indexed by Unicode
+var CodePage = [128]rune {
+	'Ç', 'ü', 'é', 'â', 'ä', 'à', 'å', 'ç', 'ê', 'ë', 'è', 'ï', 'î', 'ì', 'Ä', 'Å',
+	'É', 'æ', 'Æ', 'ô', 'ö', 'ò', 'û', 'ù', 'ÿ', 'Ö', 'Ü', '¢', '£', '¥', '₧', 'ƒ',
+	'á', 'í', 'ó', 'ú', 'ñ', 'Ñ', 'ª', 'º', '¿', '⌐', '¬', '½', '¼', '¡', '«', '»',
+	'░', '▒', '▓', '│', '┤', '╡', '╢', '╖', '╕', '╣', '║', '╗', '╝', '╜', '╛', '┐',
+	'└', '┴', '┬', '├', '─', '┼', '╞', '╟', '╚', '╔', '╩', '╦', '╠', '═', '╬', '╧',
+	'╨', '╤', '╥', '╙', '╘', '╒', '╓', '╫', '╪', '┘', '┌', '█', '▄', '▌', '▐', '▀',
+	'α', 'ß', 'Γ', 'π', 'Σ', 'σ', 'µ', 'τ', 'Φ', 'Θ', 'Ω', 'δ', '∞', 'φ', 'ε', '∩',
+	'≡', '±', '≥', '≤', '⌠', '⌡', '÷', '≈', '°', '∙', '·', '√', 'ⁿ', '²', '■', ' ',
+}
+
+// Font the machine started with, including a font loaded with --font
+var Startup = Font
+
+// Puts back the font the machine started with
+func Reset() {
+	Font = Startup
+}
+
+// Glyph for a character, returns false if the code page does not have it
+func Index(char rune) (int, bool) {
+	if char >= 0 && char < 0x80 {
+		return int(char), true
+	}
+	for i, c := range CodePage {
+		if c == char {
+			return 0x80 + i, true
+		}
+	}
+	return 0, false
+}
+
+// Character a glyph draws
+func Char(index byte) rune {
+	if index < 0x80 {
+		return rune(index)
+	}
+	return CodePage[index - 0x80]
+}
+
+// Replaces a glyph, rows are given top first with bit 7 as the leftmost pixel
+// like in font files
+func Set(index byte, rows [8]byte) {
+	for i := range rows {
+		Font[index][i] = bits.Reverse8(rows[i])
+	}
+}
+
+// Rows of a glyph in the same layout as Set
+func Get(index byte) [8]byte {
+	rows := [8]byte {}
+	for i := range rows {
+		rows[i] = bits.Reverse8(Font[index][i])
+	}
+	return rows
+}
+
+// Reads a PSF (version 1 or 2) or BDF font into the current font and makes it
+// the startup font, characters the file does not have keep their glyph
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var glyphs map[int][8]byte
+	if len(data) >= 4 && data[0] == 0x36 && data[1] == 0x04 {
+		glyphs, err = psf1(data)
+	} else if len(data) >= 32 && binary.LittleEndian.Uint32(data) == 0x864ab572 {
+		glyphs, err = psf2(data)
+	} else if bytes.HasPrefix(data, []byte("STARTFONT")) == true {
+		glyphs, err = bdf(data)
+	} else {
+		return fmt.Errorf("not a PSF or BDF font")
+	}
+	if err != nil {
+		return err
+	}
+	for index, glyph := range glyphs {
+		Font[index] = glyph
+	}
+	Startup = Font
+	return nil
+}
+
+// Fits rows with bit 7 as the leftmost pixel into a glyph, taller glyphs have
+// their rows merged so thin strokes survive
+func fit(rows []byte) [8]byte {
+	glyph := [8]byte {}
+	for i, row := range rows {
+		if len(rows) <= 8 {
+			glyph[i] = bits.Reverse8(row)
+		} else {
+			glyph[i * 8 / len(rows)] |= bits.Reverse8(row)
+		}
+	}
+	return glyph
+}
+
+// Places glyphs by their Unicode table, or by their position without one
+func place(glyphs [][8]byte, table [][]rune) map[int][8]byte {
+	placed := map[int][8]byte {}
+	for i, glyph := range glyphs {
+		if table == nil {
+			if i < len(Font) {
+				placed[i] = glyph
+			}
+			continue
+		}
+		for _, char := range table[i] {
+			if index, ok := Index(char); ok == true {
+				placed[index] = glyph
+			}
+		}
+	}
+	return placed
+}
+
+func psf1(data []byte) (map[int][8]byte, error) {
+	mode, height := int(data[2]), int(data[3])
+	count := 256
+	if mode & 1 == 1 {
+		count = 512
+	}
+	if height == 0 || len(data) < 4 + count * height {
+		return nil, fmt.Errorf("PSF font is cut short")
+	}
+	glyphs := [][8]byte {}
+	for i := 0; i < count; i++ {
+		glyphs = append(glyphs, fit(data[4 + i * height:4 + (i + 1) * height]))
+	}
+	if mode & 2 == 0 {
+		return place(glyphs, nil), nil
+	}
+
+	// Each glyph lists 16 bit characters up to 0xffff, sequences after 0xfffe
+	// are combined characters and are left out
+	table := make([][]rune, count)
+	at := 4 + count * height
+	for i := 0; i < count && at + 1 < len(data); i++ {
+		sequence := false
+		for at + 1 < len(data) {
+			char := binary.LittleEndian.Uint16(data[at:])
+			at += 2
+			if char == 0xffff {
+				break
+			} else if char == 0xfffe {
+				sequence = true
+			} else if sequence == false {
+				table[i] = append(table[i], rune(char))
+			}
+		}
+	}
+	return place(glyphs, table), nil
+}
+
+func psf2(data []byte) (map[int][8]byte, error) {
+	flags := binary.LittleEndian.Uint32(data[12:])
+	header := int(binary.LittleEndian.Uint32(data[8:]))
+	count := int(binary.LittleEndian.Uint32(data[16:]))
+	size := int(binary.LittleEndian.Uint32(data[20:]))
+	height := int(binary.LittleEndian.Uint32(data[24:]))
+	width := int(binary.LittleEndian.Uint32(data[28:]))
+	stride := (width + 7) / 8
+	if height == 0 || width == 0 || size < height * stride || count > 0x10000 || header < 32 || len(data) < header + count * size {
+		return nil, fmt.Errorf("PSF font is cut short")
+	}
+	// Glyphs wider than 8 pixels keep their left 8 columns
+	glyphs := [][8]byte {}
+	for i := 0; i < count; i++ {
+		rows := []byte {}
+		for row := 0; row < height; row++ {
+			rows = append(rows, data[header + i * size + row * stride])
+		}
+		glyphs = append(glyphs, fit(rows))
+	}
+	if flags & 1 == 0 {
+		return place(glyphs, nil), nil
+	}
+
+	// Each glyph lists UTF-8 characters up to 0xff, sequences after 0xfe are
+	// combined characters and are left out
+	table := make([][]rune, count)
+	at := header + count * size
+	for i := 0; i < count && at < len(data); i++ {
+		sequence := false
+		for at < len(data) {
+			if data[at] == 0xff {
+				at++
+				break
+			} else if data[at] == 0xfe {
+				sequence = true
+				at++
+				continue
+			}
+			char, n := utf8.DecodeRune(data[at:])
+			at += n
+			if sequence == false {
+				table[i] = append(table[i], char)
+			}
+		}
+	}
+	return place(glyphs, table), nil
+}
+
+func bdf(data []byte) (map[int][8]byte, error) {
+	glyphs := map[int][8]byte {}
+	unicode := false
+	boxHeight, boxX, boxY := 0, 0, 0
+	encoding := -1
+	height, x, y := 0, 0, 0
+	rows := []byte {}
+	bitmap := false
+	line := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		numbers := []int {}
+		for _, field := range fields[1:] {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				break
+			}
+			numbers = append(numbers, n)
+		}
+
+		if bitmap == true && fields[0] != "ENDCHAR" {
+			// Rows are hex with the leftmost pixel in the top bit, only the
+			// first 8 columns of the font box are kept
+			digits := fields[0]
+			if len(digits) > 4 {
+				digits = digits[:4]
+			}
+			value, err := strconv.ParseUint(digits, 16, 16)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid bitmap row", line)
+			}
+			row := value << (16 - 4 * len(digits))
+			if shift := x - boxX; shift >= 0 {
+				row >>= shift
+			} else {
+				row <<= -shift
+			}
+			rows = append(rows, byte(row >> 8))
+			continue
+		}
+
+		switch fields[0] {
+		case "FONTBOUNDINGBOX":
+			if len(numbers) < 4 {
+				return nil, fmt.Errorf("line %d: invalid bounding box", line)
+			}
+			boxHeight, boxX, boxY = numbers[1], numbers[2], numbers[3]
+		case "CHARSET_REGISTRY":
+			unicode = len(fields) > 1 && strings.Contains(strings.ToUpper(fields[1]), "ISO10646")
+		case "ENCODING":
+			encoding = -1
+			if len(numbers) > 0 {
+				encoding = numbers[0]
+			}
+		case "BBX":
+			if len(numbers) < 4 {
+				return nil, fmt.Errorf("line %d: invalid glyph box", line)
+			}
+			height, x, y = numbers[1], numbers[2], numbers[3]
+		case "BITMAP":
+			bitmap = true
+			rows = []byte {}
+		case "ENDCHAR":
+			bitmap = false
+			index, ok := encoding, encoding >= 0 && encoding < len(Font)
+			if unicode == true {
+				index, ok = Index(rune(encoding))
+			}
+			if ok == false || boxHeight <= 0 {
+				continue
+			}
+			// The glyph sits on the baseline of the font box
+			cell := make([]byte, boxHeight)
+			top := boxHeight + boxY - (y + height)
+			for i, row := range rows {
+				if top + i >= 0 && top + i < boxHeight {
+					cell[top + i] = row
+				}
+			}
+			glyphs[index] = fit(cell)
+		}
+	}
+	return glyphs, scanner.Err()
+}
@@ -19,3 +19,13 @@ require (
 	golang.org/x/sys v0.22.0 // indirect
 	golang.org/x/text v0.16.0 // indirect
 )
+
+require (
+	las v0.0.0
+	lld v0.0.0
+)
+
+replace (
+	las => ../las
+	lld => ../l2ld
+)
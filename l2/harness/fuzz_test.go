@@ -0,0 +1,26 @@
+package harness
+
+import (
+	"testing"
+)
+
+// Whatever a byte stream decodes to, the emulator must not crash the host.
+// Crashers found by go test -fuzz are kept in testdata/fuzz/FuzzCPU and run as
+// regression tests by go test.
+func FuzzCPU(f *testing.F) {
+	for _, source := range []string {
+		"_start:\nmov r1 6\nmov r2 0\ndiv r3 r1 r2\nhlt\n",
+		"_start:\nset 32\nmov r1 0x7fffffff\nmov r2 0xffffffff\nldd r3 r1 0\nstd r3 r2 0\nhlt\n",
+		"_start:\nmov r1 1\nlea r2 worker\nipi r1 r2\nhlt\nworker:\ncid r1\nhlt\n",
+		"_start:\ncall f\nhlt\nf:\npush r1\npop r2\nret\n",
+	} {
+		image, err := Build(source, false)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(image, uint8(1))
+	}
+	f.Fuzz(func(t *testing.T, image []byte, cores uint8) {
+		RunImage(image, Options{MaxInstructions: 2000, MaxCycles: 200000, Cores: int(cores % 4) + 1})
+	})
+}
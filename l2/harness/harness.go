@@ -0,0 +1,299 @@
+// Package harness runs L2 programs from go test.
+//
+// A program is assembled and linked in-process, loaded at address 0 like a
+// BIOS ROM and run headless on a fresh machine until every core halts or a
+// limit is reached. The result keeps a copy of the registers, memory and
+// screen, so assertions can be made after the run:
+//
+//	m := harness.Run(t, "_start:\nmov r1 5\nhlt\n", harness.Options{})
+//	m.ExpectStatus(t, 0)
+//	m.ExpectRegister(t, "R1", 5)
+package harness
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+	"las/assembler"
+	"lld/linker"
+	"luna_l2/bios"
+	"luna_l2/capture"
+	"luna_l2/config"
+	"luna_l2/cpu"
+	"luna_l2/types"
+	"luna_l2/font"
+	"luna_l2/video"
+)
+
+type Options struct {
+	// Typed in order whenever the program waits for a key (interrupt 6),
+	// which returns 0 once they run out
+	Keys string
+	// Session log to record the keys to, and one to replay before Keys are
+	// typed, see cpu.Record and cpu.LoadReplay
+	Record string
+	Replay string
+	// 0 means 1000000, so a broken program cannot hang the test
+	MaxInstructions uint64
+	// 0 means 100000000, which also stops a machine that only waits, like
+	// halted cores with a DMA transfer running
+	MaxCycles uint64
+	// 0 means 1, more than cpu.MaxCores runs cpu.MaxCores
+	Cores int
+	// Installed memory, 0 means 0x10000
+	Memory uint32
+	PIE bool
+	// Keeps every step, so StepBack can undo them once the run is over
+	History bool
+	// Captures written like --screenshot-at and --record-video do, taken
+	// every capture.FrameCycles cycles
+	Screenshot string
+	ScreenshotAt uint64
+	Video string
+}
+
+// State of the machine once the run is over
+type Machine struct {
+	// 0 if every core halted, cpu.ExitLimit if a limit stopped the machine
+	Status int
+	// Which limit was reached, empty if none was
+	Limit string
+	Instructions uint64
+	Cycles uint64
+	// Registers of every core, by core ID
+	Registers [][]types.Register
+	Memory []byte
+	// VRAM in the video mode the program left the screen in
+	Screen []byte
+	Mode int
+	Palette [256]color.NRGBA
+	// The screen as the window would show it, with the sprites and tiles
+	Frame *image.Paletted
+}
+
+// The emulator keeps its state in globals, so only one program runs at a time
+var lock sync.Mutex
+// Memory the last run may have written, cleared before the next one
+var installed uint32 = 0
+
+// Assembles and links a single source file into a bootable image. las and
+// l2ld print their diagnostics as usual.
+func Build(source string, pie bool) ([]byte, error) {
+	assembler.Reset()
+	object, ok := assembler.Assemble("test.s", []byte(source))
+	if ok == false {
+		return nil, fmt.Errorf("assembly failed")
+	}
+	image, ok := linker.Link([][]byte {object}, pie)
+	if ok == false {
+		return nil, fmt.Errorf("linking failed")
+	}
+	return image, nil
+}
+
+// Builds and runs a program, failing the test if it does not build
+func Run(t testing.TB, source string, options Options) *Machine {
+	t.Helper()
+	lock.Lock()
+	defer lock.Unlock()
+
+	image, err := Build(source, options.PIE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return run(image, options)
+}
+
+// Runs an image that was already built
+func RunImage(image []byte, options Options) *Machine {
+	lock.Lock()
+	defer lock.Unlock()
+	return run(image, options)
+}
+
+func run(image []byte, options Options) *Machine {
+	if options.MaxInstructions == 0 {
+		options.MaxInstructions = 1000000
+	}
+	if options.MaxCycles == 0 {
+		options.MaxCycles = 100000000
+	}
+	if options.Cores == 0 {
+		options.Cores = 1
+	}
+	options.Cores = min(options.Cores, cpu.MaxCores)
+	if options.Memory == 0 {
+		options.Memory = 0x10000
+	}
+
+	types.MemorySize = installed
+	cpu.Reset()
+	cpu.Connect()
+	types.MemorySize = options.Memory
+	installed = options.Memory
+
+	cpu.Headless = true
+	cpu.ClockSpeed = 0
+	cpu.CoreCount = options.Cores
+	cpu.Scheduler = "rr"
+	cpu.MaxInstructions = options.MaxInstructions
+	cpu.MaxCycles = options.MaxCycles
+	cpu.History = options.History
+	cpu.HistorySize = int(options.MaxInstructions) * options.Cores
+
+	bios.TypeOut = false
+	bios.KeyTrap = false
+	bios.Disks = nil
+	bios.Devices = config.Devices{Audio: false, DMA: true, Keyboard: true}
+	// Keys are typed like the window does once the program waits, so they are
+	// recorded and replayed like live ones
+	keys := []rune(options.Keys)
+	wait := bios.Input
+	bios.Input = func() (uint32, bool) {
+		if len(keys) > 0 {
+			cpu.Key(uint32(keys[0]))
+			keys = keys[1:]
+		}
+		return wait()
+	}
+	bios.Sleep = func(time.Duration) {}
+	defer func() {
+		bios.Input = nil
+		bios.Sleep = time.Sleep
+	}()
+	if options.Replay != "" {
+		if err := cpu.LoadReplay(options.Replay); err != nil {
+			panic("harness: " + err.Error())
+		}
+	}
+	if options.Record != "" {
+		if err := cpu.Record(options.Record); err != nil {
+			panic("harness: " + err.Error())
+		}
+		defer func() {
+			cpu.Recording.Close()
+			cpu.Recording = nil
+		}()
+	}
+
+	video.Reset()
+
+	capture.Reset()
+	capture.ScreenshotPath = options.Screenshot
+	capture.ScreenshotAt = options.ScreenshotAt
+	capture.VideoPath = options.Video
+	cpu.Clock = capture.Tick
+
+	copy(cpu.Memory[:types.MemorySize], image)
+	cpu.InitializeCores()
+	cpu.Execute()
+	capture.Finish()
+	return state()
+}
+
+// Undoes the last step of the previous run, which needs Options.History, and
+// returns the machine as it was before the step. Returns false once every
+// step is undone.
+func StepBack() (*Machine, bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	if cpu.StepBack() == false {
+		return nil, false
+	}
+	return state(), true
+}
+
+// Copy of the machine as it is now
+func state() *Machine {
+	machine := &Machine{
+		Limit: cpu.Limit,
+		Instructions: cpu.Instructions,
+		Cycles: cpu.Cycles,
+		Memory: bytes.Clone(cpu.Memory[:types.MemorySize]),
+		Screen: bytes.Clone(video.VRAM()),
+		Mode: video.ActiveMode,
+		Palette: video.Palette,
+		Frame: video.Image(),
+	}
+	if cpu.Limit != "" {
+		machine.Status = cpu.ExitLimit
+	}
+	cpu.Current.Bits32 = types.Bits32
+	for _, core := range cpu.Cores {
+		machine.Registers = append(machine.Registers, append([]types.Register {}, core.Registers...))
+	}
+	return machine
+}
+
+// Value of a register of core 0 by name, such as "R1" or "PC"
+func (m *Machine) Register(name string) uint32 {
+	return m.CoreRegister(0, name)
+}
+
+func (m *Machine) CoreRegister(core int, name string) uint32 {
+	for _, register := range m.Registers[core] {
+		if register.Name == name {
+			return register.Value
+		}
+	}
+	panic("harness: no register named " + name)
+}
+
+// Bytes of memory starting at address, past the end of memory reads as 0
+func (m *Machine) Bytes(address uint32, length int) []byte {
+	data := make([]byte, length)
+	for i := range data {
+		if uint64(address) + uint64(i) < uint64(len(m.Memory)) {
+			data[i] = m.Memory[address + uint32(i)]
+		}
+	}
+	return data
+}
+
+// Text on screen starting at a cursor position (column and row of console cells),
+// characters that cannot be read back are '?' and glyphs above 127 are the
+// Unicode characters they draw
+func (m *Machine) Text(column int, row int, length int) string {
+	text := []rune {}
+	for i := 0; i < length; i++ {
+		ch, ok := video.CharAt(m.Screen, m.Mode, column + i, row)
+		if ok == false {
+			text = append(text, '?')
+			continue
+		}
+		text = append(text, font.Char(byte(ch)))
+	}
+	return string(text)
+}
+
+func (m *Machine) ExpectStatus(t testing.TB, want int) {
+	t.Helper()
+	if m.Status != want {
+		t.Errorf("status = %d, want %d (%s)", m.Status, want, m.Limit)
+	}
+}
+
+func (m *Machine) ExpectRegister(t testing.TB, name string, want uint32) {
+	t.Helper()
+	if got := m.Register(name); got != want {
+		t.Errorf("%s = 0x%x, want 0x%x", name, got, want)
+	}
+}
+
+func (m *Machine) ExpectMemory(t testing.TB, address uint32, want []byte) {
+	t.Helper()
+	if got := m.Bytes(address, len(want)); bytes.Equal(got, want) == false {
+		t.Errorf("memory at 0x%x = % x, want % x", address, got, want)
+	}
+}
+
+func (m *Machine) ExpectText(t testing.TB, column int, row int, want string) {
+	t.Helper()
+	if got := m.Text(column, row, len([]rune(want))); got != want {
+		t.Errorf("text at %d,%d = %q, want %q", column, row, got, want)
+	}
+}
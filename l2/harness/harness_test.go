@@ -0,0 +1,810 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"luna_l2/cpu"
+	"luna_l2/font"
+)
+
+func TestRegisters(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 6
+mov r2 7
+mul r1 r1 r2
+hlt
+`, Options{})
+	m.ExpectStatus(t, 0)
+	m.ExpectRegister(t, "R1", 42)
+}
+
+func TestMemory(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 0x1234
+mov r2 0x8000
+stw r1 r2 0
+stb r1 r2 2
+hlt
+`, Options{})
+	m.ExpectMemory(t, 0x8000, []byte{0x12, 0x34, 0x34})
+}
+
+func TestKeysAndText(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 1
+int 4
+int 6
+int 6
+mov r5 r1
+int 6
+hlt
+`, Options{Keys: "hi"})
+	m.ExpectText(t, 0, 0, "hi")
+	m.ExpectRegister(t, "R5", 'i')
+	m.ExpectRegister(t, "R1", 0)
+}
+
+func TestSession(t *testing.T) {
+	// Echoes a key that arrives while the loop runs, then waits for another
+	source := `_start:
+mov r1 1
+int 4
+mov r4 40
+loop:
+dec r4
+jnz r4 loop
+int 6
+mov r5 r1
+hlt
+`
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.log")
+	if err := os.WriteFile(script, []byte("key 20 0 65\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	session := filepath.Join(dir, "session.log")
+	first := Run(t, source, Options{Keys: "b", Replay: script, Record: session})
+	first.ExpectText(t, 0, 0, "Ab")
+	first.ExpectRegister(t, "R5", 'b')
+
+	log, err := os.ReadFile(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(log), "\nkey ") == false || strings.Contains(string(log), "\nwait ") == false {
+		t.Fatalf("session log lacks a key or a wait event:\n%s", log)
+	}
+
+	second := Run(t, source, Options{Replay: session})
+	if second.Cycles != first.Cycles || second.Instructions != first.Instructions {
+		t.Errorf("replay ran %d cycles and %d instructions, want %d and %d", second.Cycles, second.Instructions, first.Cycles, first.Instructions)
+	}
+	if fmt.Sprint(second.Registers) != fmt.Sprint(first.Registers) {
+		t.Errorf("registers after replay = %v, want %v", second.Registers, first.Registers)
+	}
+	if bytes.Equal(second.Screen, first.Screen) == false {
+		t.Errorf("screen after replay differs")
+	}
+}
+
+// Bits32, halted state and pending interrupts of every core
+func cores() string {
+	text := ""
+	for _, core := range cpu.Cores {
+		text += fmt.Sprintf("core %d: %v %v %v\n", core.ID, core.Bits32, core.Halted, core.Pending)
+	}
+	return text
+}
+
+type snapshot struct {
+	Machine *Machine
+	Cores string
+}
+
+// Runs a program with its history kept and steps back to the start, checking
+// every state against a run stopped after as many instructions. Returns the
+// states of the stopped runs by instruction count.
+func expectStepBack(t *testing.T, source string, options Options) map[uint64]snapshot {
+	t.Helper()
+	want := map[uint64]snapshot {}
+	count := Run(t, source, options).Instructions
+	for i := uint64(1); i < count; i++ {
+		limited := options
+		limited.MaxInstructions = i
+		want[i] = snapshot{Run(t, source, limited), cores()}
+	}
+
+	options.History = true
+	Run(t, source, options)
+	for {
+		m, ok := StepBack()
+		if ok == false {
+			break
+		}
+		state, found := want[m.Instructions]
+		if found == false {
+			continue
+		}
+		if fmt.Sprint(m.Registers) != fmt.Sprint(state.Machine.Registers) {
+			t.Errorf("registers after stepping back to instruction %d = %v, want %v", m.Instructions, m.Registers, state.Machine.Registers)
+		}
+		if bytes.Equal(m.Memory, state.Machine.Memory) == false {
+			t.Errorf("memory after stepping back to instruction %d differs", m.Instructions)
+		}
+		if m.Cycles != state.Machine.Cycles {
+			t.Errorf("cycles after stepping back to instruction %d = %d, want %d", m.Instructions, m.Cycles, state.Machine.Cycles)
+		}
+		if got := cores(); got != state.Cores {
+			t.Errorf("cores after stepping back to instruction %d =\n%swant\n%s", m.Instructions, got, state.Cores)
+		}
+		if t.Failed() == true {
+			break
+		}
+	}
+	if cpu.Instructions != 0 {
+		t.Errorf("stepping back stopped at instruction %d", cpu.Instructions)
+	}
+	return want
+}
+
+func TestStepBack(t *testing.T) {
+	// Memory is written by instructions, interrupt 48 and a DMA fill
+	source := `_start:
+mov r1 5
+mov r2 0x8000
+stw r1 r2 0
+set 32
+bits 32
+mov r1 0x12345678
+std r1 r2 4
+mov r1 0x41
+mov r2 0x8010
+int 48
+mov r1 0xaa
+mov r2 0x8020
+mov r3 16
+mov r4 0x100
+mov r5 0
+int 11
+wait:
+int 12
+jnz r1 wait
+set 16
+hlt
+`
+	// The first instruction is the jump through the start address in the
+	// first two bytes
+	want := expectStepBack(t, source, Options{})
+
+	m := Run(t, source, Options{History: true})
+	m.ExpectMemory(t, 0x8020, bytes.Repeat([]byte{0xaa}, 16))
+	for _, write := range []struct {
+		Address uint32
+		Instruction uint64
+	} {
+		{0x8000, 3},
+		{0x8004, 6},
+		{0x8010, 9},
+	} {
+		pc, core, instruction, found := cpu.LastWrite(write.Address)
+		if found == false || core != 0 || instruction != write.Instruction || pc != want[write.Instruction].Machine.Register("PC") {
+			t.Errorf("last write to 0x%x = pc 0x%x core %d instruction %d (%v), want pc 0x%x core 0 instruction %d", write.Address, pc, core, instruction, found, want[write.Instruction].Machine.Register("PC"), write.Instruction)
+		}
+	}
+	// The DMA transfer writes while the program polls its status
+	if _, _, instruction, found := cpu.LastWrite(0x802f); found == false || instruction < 15 {
+		t.Errorf("last write to 0x802f at instruction %d (%v), want one while polling", instruction, found)
+	}
+	if _, _, _, found := cpu.LastWrite(0x8030); found == true {
+		t.Errorf("0x8030 was never written but has a last write")
+	}
+}
+
+func TestStepBackIPI(t *testing.T) {
+	source := `_start:
+mov r1 1
+lea r2 worker
+ipi r1 r2
+mov r3 1
+hlt
+worker:
+cid r4
+hlt
+`
+	want := expectStepBack(t, source, Options{Cores: 2})
+	// The IPI waits on core 1 right after it is sent
+	if strings.Contains(want[4].Cores, "core 1: false true [{") == false {
+		t.Errorf("cores after the IPI =\n%s", want[4].Cores)
+	}
+}
+
+func TestBranchRelaxation(t *testing.T) {
+	// The references to a 32-bit label make the span too long for a short
+	// branch once l2ld widens them
+	m := Run(t, `_start:
+mov r2 0
+jmp skip
+back:
+`+strings.Repeat("mov r1 far\n", 22)+`skip:
+jnz r2 back
+mov r3 1
+hlt
+bits 32
+far:
+.byte 0
+`, Options{})
+	m.ExpectStatus(t, 0)
+	m.ExpectRegister(t, "R3", 1)
+}
+
+func TestPIE(t *testing.T) {
+	source := `_start:
+lea r2 value
+ldw r1 r2 0
+call double
+hlt
+double:
+add r1 r1 r1
+ret
+value:
+.word 0x1234
+`
+	m := Run(t, source, Options{PIE: true})
+	m.ExpectRegister(t, "R1", 0x2468)
+
+	// A loader at address 0 jumps to the image copied to 0x4000, whose first
+	// two bytes are the offset of _start
+	pie, err := Build(source, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := 0x4000 + uint32(binary.BigEndian.Uint16(pie))
+	loader, err := Build(fmt.Sprintf("_start:\nmov r1 0x%x\njmp r1\n", start), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := make([]byte, 0x4000)
+	copy(image, loader)
+	m = RunImage(append(image, pie...), Options{})
+	m.ExpectStatus(t, 0)
+	m.ExpectRegister(t, "R1", 0x2468)
+	if address := m.Register("R2"); address < 0x4000 || address >= 0x4000 + uint32(len(pie)) {
+		t.Errorf("lea gave 0x%x, want an address in the copy", address)
+	}
+
+	// Using a label as an immediate is error 5
+	stderr := os.Stderr
+	read, write, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = write
+	_, err = Build("_start:\nmov r1 value\nhlt\nvalue:\n.word 1\n", true)
+	write.Close()
+	os.Stderr = stderr
+	output, _ := io.ReadAll(read)
+	if err == nil || strings.Contains(string(output), "absolute reference cannot be used when making a PIE") == false {
+		t.Errorf("absolute reference under -pie gave %v: %s", err, output)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	m := Run(t, `_start:
+loop:
+jmp loop
+`, Options{MaxInstructions: 100})
+	m.ExpectStatus(t, cpu.ExitLimit)
+	if m.Instructions != 100 {
+		t.Errorf("instructions = %d, want 100", m.Instructions)
+	}
+
+	// Only the cycle limit stops a halted core waiting on a long transfer
+	m = Run(t, `_start:
+set 32
+bits 32
+mov r1 0
+mov r2 0x8000
+mov r3 0x7fffffff
+mov r4 0x100
+mov r5 0
+int 11
+hlt
+`, Options{MaxCycles: 100000})
+	m.ExpectStatus(t, cpu.ExitLimit)
+	if m.Limit != "cycle limit reached" {
+		t.Errorf("limit = %q, want the cycle limit", m.Limit)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	m := Run(t, `_start:
+mov r2 15
+mov r3 0
+mov r4 26
+mov r5 65
+line:
+mov r1 r5
+int 1
+mov r1 10
+int 1
+inc r5
+dec r4
+jnz r4 line
+mov r1 97
+int 1
+mov r1 9
+int 1
+mov r1 98
+int 1
+mov r1 120
+int 1
+mov r1 8
+int 1
+hlt
+`, Options{})
+	m.ExpectText(t, 0, 0, "C")
+	m.ExpectText(t, 0, 23, "Z")
+	m.ExpectText(t, 0, 24, "a       b  ")
+}
+
+func TestEscapes(t *testing.T) {
+	m := Run(t, `_start:
+lea r4 text
+loop:
+ldb r1 r4 0
+jz r1 done
+mov r2 15
+mov r3 0
+int 1
+inc r4
+jmp loop
+done:
+hlt
+text:
+.asciz "junk\033[2J\033[5;10HX\033[1;31mY\033[0m\033[10DZ"
+`, Options{})
+	m.ExpectText(t, 0, 0, "    ")
+	m.ExpectText(t, 0, 4, " Z       XY ")
+	// Bold red is drawn in bright red
+	red := false
+	for y := 32; y < 40; y++ {
+		for x := 80; x < 88; x++ {
+			if m.Screen[y*320 + x] == 0xe0 {
+				red = true
+			}
+		}
+	}
+	if red == false {
+		t.Errorf("Y is not bright red")
+	}
+}
+
+func TestTextServices(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 4
+int 19
+mov r1 3
+mov r2 2
+int 14
+lea r1 text
+mov r2 15
+mov r3 4
+int 17
+mov r1 255
+mov r4 16
+int 18
+mov r1 1234
+mov r4 0
+int 18
+int 15
+mov r5 r1
+mov r6 r2
+mov r1 4
+mov r2 2
+int 16
+hlt
+text:
+.asciz "hi "
+`, Options{})
+	m.ExpectText(t, 3, 2, "hi ff1234")
+	m.ExpectRegister(t, "R5", 12)
+	m.ExpectRegister(t, "R6", 2)
+	m.ExpectRegister(t, "R1", 'i')
+	if m.Screen[0] != 4 {
+		t.Errorf("screen not cleared to colour 4")
+	}
+}
+
+func TestPalette(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 0x8000
+int 24
+mov r1 0x8000
+mov r2 0x12
+stb r2 r1 0
+int 23
+mov r1 7
+mov r2 1
+mov r3 2
+mov r4 3
+int 21
+mov r1 0xff
+int 22
+hlt
+`, Options{})
+	m.ExpectMemory(t, 0x8000 + 0xff*3, []byte{0xff, 0xff, 0xff})
+	m.ExpectRegister(t, "R1", 0xff)
+	if m.Palette[0] != (color.NRGBA{0x12, 0, 0, 255}) || m.Palette[7] != (color.NRGBA{1, 2, 3, 255}) {
+		t.Errorf("palette = %v %v", m.Palette[0], m.Palette[7])
+	}
+}
+
+func TestVideoModes(t *testing.T) {
+	for _, mode := range []uint32{1, 2, 3} {
+		m := Run(t, `_start:
+mov r1 `+string(rune('0'+mode))+`
+int 26
+mov r6 r1
+mov r1 79
+mov r2 59
+int 14
+mov r1 72
+mov r2 15
+mov r3 0
+int 1
+mov r1 105
+int 1
+int 27
+hlt
+`, Options{})
+		m.ExpectRegister(t, "R6", 1)
+		m.ExpectRegister(t, "R1", mode)
+		m.ExpectRegister(t, "R2", 640)
+		m.ExpectRegister(t, "R4", 80)
+		rows := m.Register("R5")
+		// "H" is in the last column and "i" wraps around and scrolls
+		m.ExpectText(t, 79, int(rows) - 2, "H")
+		m.ExpectText(t, 0, int(rows) - 1, "i ")
+	}
+}
+
+func TestDrawing(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 10
+mov r2 20
+mov r3 5
+int 28
+int 29
+mov r7 r1
+mov r1 0
+mov r2 0
+mov r3 9
+mov r4 9
+mov r5 3
+int 32
+mov r1 -5
+mov r2 100
+mov r3 10
+mov r4 2
+mov r5 4
+int 34
+mov r1 200
+mov r2 100
+mov r3 10
+mov r4 6
+int 35
+mov r1 0x8000
+mov r2 0x0201
+stw r2 r1 0
+mov r2 0x0003
+stw r2 r1 2
+mov r2 300
+mov r3 150
+mov r4 2
+mov r5 2
+mov r6 2
+int 37
+hlt
+`, Options{})
+	m.ExpectRegister(t, "R7", 5)
+	pixel := func(x int, y int) byte {
+		return m.Screen[y*320 + x]
+	}
+	if pixel(0, 0) != 3 || pixel(9, 9) != 3 || pixel(5, 5) != 3 || pixel(5, 4) != 0 {
+		t.Errorf("line drawn wrong")
+	}
+	if pixel(0, 100) != 4 || pixel(4, 101) != 4 || pixel(5, 100) != 0 || pixel(0, 102) != 0 {
+		t.Errorf("rectangle not clipped")
+	}
+	if pixel(210, 100) != 6 || pixel(200, 90) != 6 || pixel(200, 100) != 0 {
+		t.Errorf("circle drawn wrong")
+	}
+	if pixel(301, 150) != 1 || pixel(300, 150) != 0 || pixel(300, 151) != 0 || pixel(301, 151) != 3 {
+		t.Errorf("blit drawn wrong: %v", []byte{pixel(300, 150), pixel(301, 150), pixel(300, 151), pixel(301, 151)})
+	}
+}
+
+func TestSprites(t *testing.T) {
+	m := Run(t, `_start:
+lea r1 table
+lea r2 pattern0
+stw r2 r1 10
+lea r2 pattern1
+stw r2 r1 26
+stw r2 r1 42
+mov r2 3
+int 38
+lea r1 tiles
+lea r2 map
+mov r3 1
+mov r4 1
+int 39
+mov r1 0
+int 41
+mov r5 r1
+mov r1 1
+int 41
+mov r6 r1
+mov r1 2
+int 41
+mov r7 r1
+hlt
+table:
+.byte 0 10 0 10 2 2 3 0x10 0 0 0 0 0 0 0 0
+.byte 0 11 0 11 1 1 1 0 0 0 0 0 0 0 0 0
+.byte 0 16 0 16 1 1 1 0 0 0 0 0 0 0 0 0
+pattern0:
+.byte 1 2 3 4
+pattern1:
+.byte 5
+map:
+.byte 1
+tiles:
+.byte 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+.byte 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+.byte 7 0 0 0 0 0 0 0
+`, Options{})
+	m.ExpectRegister(t, "R5", 1)
+	m.ExpectRegister(t, "R6", 1)
+	m.ExpectRegister(t, "R7", 2)
+	pixel := func(x int, y int) byte {
+		return m.Frame.Pix[y*320 + x]
+	}
+	// Sprite 0 is flipped and drawn over sprite 1, sprite 2 over the tiles
+	want := map[[2]int]byte{{10, 10}: 0x12, {11, 10}: 0x11, {11, 11}: 0x13, {0, 0}: 7, {8, 0}: 7, {1, 0}: 0, {16, 16}: 5}
+	for at, value := range want {
+		if got := pixel(at[0], at[1]); got != value {
+			t.Errorf("pixel at %v = 0x%x, want 0x%x", at, got, value)
+		}
+	}
+	if m.Screen[0] != 0 {
+		t.Errorf("tiles were written to VRAM")
+	}
+}
+
+func TestVBlank(t *testing.T) {
+	m := Run(t, `_start:
+lea r1 handler
+ivec r1
+mov r1 7
+int 45
+hlt
+hlt
+mov r1 0
+int 45
+int 46
+mov r8 r1
+mov r1 1
+int 43
+mov r1 0
+mov r2 0
+mov r3 9
+int 28
+int 44
+mov r1 1
+int 28
+hlt
+handler:
+pop r9
+inc r10
+ret
+`, Options{})
+	m.ExpectStatus(t, 0)
+	m.ExpectRegister(t, "R9", 7)
+	m.ExpectRegister(t, "R10", 2)
+	m.ExpectRegister(t, "R8", 2)
+	// The pixel drawn after the flip is in VRAM but not on screen yet
+	if m.Frame.Pix[0] != 9 || m.Frame.Pix[1] != 0 || m.Screen[1] != 9 {
+		t.Errorf("frame = % x, VRAM = % x", m.Frame.Pix[:2], m.Screen[:2])
+	}
+	if m.Cycles < 3 * 1158000 / 60 {
+		t.Errorf("cycles = %d, the flip did not wait for a vblank", m.Cycles)
+	}
+}
+
+func TestCapture(t *testing.T) {
+	// Two pixels, two frames of vblanks, then a letter in the text mode and
+	// two more frames
+	dir := t.TempDir()
+	screenshot := filepath.Join(dir, "screen.png")
+	video := filepath.Join(dir, "video.gif")
+	m := Run(t, `_start:
+mov r1 0
+mov r2 0x0909
+int 3
+mov r4 12
+first:
+int 42
+dec r4
+jnz r4 first
+mov r1 1
+int 26
+mov r1 65
+mov r2 15
+mov r3 0
+int 1
+mov r4 12
+second:
+int 42
+dec r4
+jnz r4 second
+hlt
+`, Options{Screenshot: screenshot, ScreenshotAt: 150000, Video: video})
+	m.ExpectStatus(t, 0)
+
+	file, err := os.Open(screenshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	shot, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shot.Bounds() != image.Rect(0, 0, 320, 200) {
+		t.Errorf("screenshot size = %v, want 320x200", shot.Bounds())
+	}
+	if paletted, ok := shot.(*image.Paletted); ok == false || paletted.ColorIndexAt(1, 0) != 9 || paletted.ColorIndexAt(2, 0) != 0 {
+		t.Errorf("screenshot does not show the two pixels")
+	}
+
+	file, err = os.Open(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A frame is taken every 115800 cycles and once more when the machine
+	// stops, the unchanged ones lengthen the frame before them
+	if len(animation.Image) != 3 || fmt.Sprint(animation.Delay) != "[10 20 30]" {
+		t.Fatalf("video has %d frames with delays %v, want 3 with [10 20 30]", len(animation.Image), animation.Delay)
+	}
+	if animation.Config.Width != 640 || animation.Config.Height != 400 {
+		t.Errorf("video size = %dx%d, want the largest frame", animation.Config.Width, animation.Config.Height)
+	}
+	for i, want := range []image.Rectangle {image.Rect(0, 0, 320, 200), image.Rect(0, 0, 320, 200), image.Rect(0, 0, 640, 400)} {
+		if animation.Image[i].Rect != want {
+			t.Errorf("frame %d size = %v, want %v", i, animation.Image[i].Rect, want)
+		}
+	}
+	if bytes.Count(animation.Image[0].Pix, []byte{0}) != len(animation.Image[0].Pix) {
+		t.Errorf("first frame is not blank")
+	}
+	if bytes.Equal(animation.Image[1].Pix[:3], []byte{9, 9, 0}) == false {
+		t.Errorf("second frame starts with % x, want 09 09 00", animation.Image[1].Pix[:3])
+	}
+	if bytes.Equal(animation.Image[2].Pix, m.Frame.Pix) == false {
+		t.Errorf("last frame differs from the screen")
+	}
+}
+
+func TestFont(t *testing.T) {
+	m := Run(t, `_start:
+mov r1 0xc9
+mov r2 15
+mov r3 0
+int 1
+mov r1 0x41
+lea r2 glyph
+int 47
+mov r1 0x41
+mov r2 0x8000
+int 48
+mov r1 0xc9
+mov r2 15
+int 1
+mov r1 0
+mov r2 0
+int 16
+mov r8 r1
+mov r2 15
+int 1
+hlt
+glyph:
+.byte 0x80 0x40 0x20 0x10 0x08 0x04 0x02 0x01
+`, Options{})
+	// Reading a cell gives back the glyph number, which prints the same glyph
+	m.ExpectRegister(t, "R8", 0xc9)
+	m.ExpectText(t, 0, 0, "╔╔╔")
+	m.ExpectMemory(t, 0x8000, []byte{0x80, 0x40, 0x20, 0x10, 0x08, 0x04, 0x02, 0x01})
+	if font.Font[0x41][0] != 0x01 {
+		t.Errorf("glyph row = %x, want 1", font.Font[0x41][0])
+	}
+	m = Run(t, `_start:
+mov r1 1
+int 26
+mov r1 0xc9
+mov r2 15
+mov r3 0
+int 1
+mov r1 0
+mov r2 0
+int 16
+hlt
+`, Options{})
+	m.ExpectRegister(t, "R1", 0xc9)
+	m.ExpectText(t, 0, 0, "╔")
+
+	startup := font.Startup
+	defer func() {
+		font.Startup = startup
+		font.Reset()
+	}()
+	dir := t.TempDir()
+	// A BDF glyph sits on the baseline of the font box
+	bdf := filepath.Join(dir, "font.bdf")
+	os.WriteFile(bdf, []byte(`STARTFONT 2.1
+FONTBOUNDINGBOX 8 8 0 -1
+CHARSET_REGISTRY "ISO10646"
+STARTCHAR box
+ENCODING 9484
+BBX 4 4 4 -1
+BITMAP
+F0
+80
+80
+80
+ENDCHAR
+ENDFONT
+`), 0644)
+	if err := font.Load(bdf); err != nil {
+		t.Fatal(err)
+	}
+	if got := font.Get(0xda); got != [8]byte{0, 0, 0, 0, 0x0f, 0x08, 0x08, 0x08} {
+		t.Errorf("BDF glyph = % x", got)
+	}
+	// A 16 row PSF2 glyph is squeezed and placed by its Unicode table
+	psf := binary.LittleEndian.AppendUint32(nil, 0x864ab572)
+	for _, value := range []uint32{0, 32, 1, 1, 16, 16, 8} {
+		psf = binary.LittleEndian.AppendUint32(psf, value)
+	}
+	for row := 0; row < 16; row++ {
+		psf = append(psf, byte(0x80 >> (row / 2)))
+	}
+	psf = append(psf, []byte("é\xff")...)
+	path := filepath.Join(dir, "font.psf")
+	os.WriteFile(path, psf, 0644)
+	if err := font.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := font.Get(0x82); got != [8]byte{0x80, 0x40, 0x20, 0x10, 0x08, 0x04, 0x02, 0x01} {
+		t.Errorf("PSF glyph = % x", got)
+	}
+	font.Reset()
+	if font.Get(0xda)[4] != 0x0f {
+		t.Errorf("reset did not keep the loaded font")
+	}
+}
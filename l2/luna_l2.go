@@ -2,16 +2,21 @@ package main
 
 import (	
 	"image"
-	"image/color"	
 	"os"	
 	"time"
 	"fmt"
 	"strconv"
-	"bufio"
+	"sync"
 
 	"luna_l2/bios"		
+	"luna_l2/capture"
+	"luna_l2/config"
+	"luna_l2/cpu"
+	"luna_l2/display"
+	"luna_l2/font"
 	"luna_l2/video"
 	"luna_l2/keyboard"
+	"luna_l2/terminal"
 	"luna_l2/types"
 
 	"gioui.org/app"	
@@ -21,579 +26,67 @@ import (
 	"gioui.org/op/clip"
 	"gioui.org/io/key"
 	"gioui.org/io/event"	
+	"gioui.org/unit"
 )
 
-// Basic elements of CPU
-var Registers = []types.Register {
-	{0x0000, "R0", 0},
-	{0x0001, "R1", 0},
-	{0x0002, "R2", 0},
-	{0x0003, "R3", 0},
-	{0x0004, "R4", 0},
-	{0x0005, "R5", 0},
-	{0x0006, "R6", 0},
-	{0x0007, "R7", 0},
-	{0x0008, "R8", 0},
-	{0x0009, "R9", 0},
-	{0x000a, "R10", 0},
-	{0x000b, "R11", 0},
-	{0x000c, "R12", 0},
-	{0x000d, "T1", 0},
-	{0x000e, "T2", 0},
-	{0x000f, "T3", 0},
-	{0x0010, "T4", 0},
-	{0x0011, "T5", 0},
-	{0x0012, "T6", 0},
-	{0x0013, "T7", 0},
-	{0x0014, "T8", 0},
-	{0x0015, "T9", 0},
-	{0x0016, "T10", 0},
-	{0x0017, "T11", 0},
-	{0x0018, "T12", 0},
-	{0x0019, "SP", 0},
-	{0x001a, "PC", 0},
-	{0x001b, "RE1", 0},
-	{0x001c, "RE2", 0},
-	{0x001d, "RE3", 0},
-}
-
-var Memory [0x70000000]byte
-const (
-	MEMSIZE uint32 = 0x70000000
-	MEMCAP uint32 = 0x6FFFFFFF
-)
-
-// Register controls
-func setRegister(address uint32, value uint32) {
-	for i := range Registers {
-		if Registers[i].Address == address {
-			if types.Bits32 == false {
-				Registers[i].Value = uint32(uint16(value))
-			} else {
-				Registers[i].Value = value
-			}
-		}
-	}
-}
-
-func getRegister(address uint32) uint32 {
-	for _, register := range Registers {
-		if register.Address == address {
-			return register.Value
-		}
-	}
-	return 0x0000
-}
-
-func getRegisterName[T uint32 | byte](address T) string {
-	addr := uint32(address)
-	for _, register := range Registers {
-		if register.Address == addr {
-			return register.Name
-		}
-	}
-	return ""
-}
-
-func Mapper(address uint32) byte {
-	if address < MEMSIZE {
-		return Memory[address]
-	} else {
-		return Memory[MEMCAP]
-	}
-	return Memory[0x00000000]
-}
-
-func MapperIndex(address uint32) uint32 {
-	if address < MEMSIZE {
-		return address
-	} else {
-		return MEMCAP
-	}
-	return 0x00000000	
-}
-
-// Meta-code
-var LogOn bool = false
-var Debug bool = false
-var ClockSpeed int64 = 1158000
-var Filename string = ""
-func Log(text string) {
-	if LogOn == true {
-		fmt.Println("\033[33m" + fmt.Sprintf("0x%08x: ", getRegister(0x001a)) + text + "\033[0m")
-	}
-}
+var Machine config.Machine = config.Default()
+// Milliseconds of emulated time between video frames
+var VideoInterval uint64 = 100
+// Session logs, see cpu.Record
+var RecordPath string = ""
+var ReplayPath string = ""
 func LoadSector(sector int, enforce bool) {
-	data, err := os.ReadFile(Filename)
-	if err != nil {
+	if len(Machine.Disks) == 0 {
+		return
+	}
+	if _, err := os.Stat(Machine.Disks[0]); err != nil {
 		if enforce == false {
 			fmt.Println("luna-l2: could not reload block device")
 			return
 		} else {
-			fmt.Println("luna-l2: could not open '" + Filename + "'")
+			fmt.Println("luna-l2: could not open '" + Machine.Disks[0] + "'")
 			os.Exit(1)
 		}
 	}
-	start := sector * 512
-	if start > len(data) {
-		Log("read at address " + fmt.Sprintf("0x%08x", start) + " out of bounds")	
-		return
+	if bios.ReadSector(0, uint32(sector), uint32(sector * 512)) == false {
+		cpu.Log("read at address " + fmt.Sprintf("0x%08x", sector * 512) + " out of bounds")	
 	}
-	if start + 512 > len(data) {
-		copy(Memory[start:start + 512], data[start:])
-	} else {
-		copy(Memory[start:start + 512], data[start:start + 512])
-	}
-}
-
-// CPU code
-func stall(cycles int64) { 
-	cycleTime := int64(int(time.Second)) / ClockSpeed
-	time.Sleep(time.Duration(cycleTime * cycles))
 }
-
-func execute() {
-	for {
-		ProgramCounter := getRegister(0x001a)
-		op := Mapper(ProgramCounter)
-	
-		if ProgramCounter == 0x0000 {
-			codesect := uint32(Mapper(ProgramCounter)) << 8 | uint32(Mapper(ProgramCounter + 1))
-			setRegister(0x001a, codesect)
-			continue
-		}
-
-		switch op {
-		case 0x00:
-			return
-		case 0x01:
-			// MOV
-			mode := Mapper(ProgramCounter + 1)
-			dst := Mapper(ProgramCounter + 2)
-
-			if mode == 0x01 {
-				var imm uint32 = 0
-				var next uint32 = 0
-				if types.Bits32 == false {
-					imm = uint32(uint16(Memory[ProgramCounter + 3]) << 8 | uint16(Memory[ProgramCounter + 4]))
-					next = ProgramCounter + 5
-				} else {
-					imm = uint32(Memory[ProgramCounter + 3]) << 24 | uint32(Memory[ProgramCounter + 4])	<< 16 | uint32(Memory[ProgramCounter + 5]) << 8 | uint32(Memory[ProgramCounter + 6])
-					next = ProgramCounter + 7
-				}
-				setRegister(uint32(dst), imm)
-				setRegister(0x001a, next)
-				Log("mov " + getRegisterName(uint32(dst)) + ", " + fmt.Sprintf("0x%08x", imm))
-			} else if mode == 0x02 {
-				frm := uint32(Memory[ProgramCounter+3])
-				setRegister(uint32(dst), uint32(getRegister(frm)))
-				setRegister(0x001a, ProgramCounter+4)
-				Log("mov " + getRegisterName(uint32(dst)) + ", " + getRegisterName(frm))
-			}	
-			stall(4)
-		case 0x02:
-			// HLT
-			Log("hlt")
-			for {
-				time.Sleep(time.Second)
-			}
-			setRegister(0x001a, ProgramCounter+1)
-		case 0x03:
-			// JMP	
-			mode := Memory[ProgramCounter+1]
-
-			if mode == 0x01 {
-				var loc uint32 = 0
-				if types.Bits32 == false {
-					loc = uint32(uint16(Memory[ProgramCounter + 2]) << 8 | uint16(Memory[ProgramCounter + 3]))
-				} else {
-					loc = uint32(Memory[ProgramCounter + 2]) << 24 | uint32(Memory[ProgramCounter + 3])	<< 16 | uint32(Memory[ProgramCounter + 4]) << 8 | uint32(Memory[ProgramCounter + 5])
-				}	
-				setRegister(0x001a, loc)
-				Log("jmp " + fmt.Sprintf("0x%08x", loc))
-			} else if mode == 0x02 {
-				frm := uint32(Memory[ProgramCounter+2])
-				loc := getRegister(frm)	
-				setRegister(0x001a, loc)
-				Log("jmp " + getRegisterName(frm))
-			}
-			stall(8)
-		case 0x04:
-			// INT
-			var code uint32 = 0
-			var next uint32 = 0
-			if types.Bits32 == false {
-				code = uint32(uint16(Memory[ProgramCounter + 1]) << 8 | uint16(Memory[ProgramCounter + 2]))
-				next = ProgramCounter + 3
-			} else {
-				code = uint32(Memory[ProgramCounter + 1]) << 24 | uint32(Memory[ProgramCounter + 2])	<< 16 | uint32(Memory[ProgramCounter + 3]) << 8 | uint32(Memory[ProgramCounter + 4])
-				next = ProgramCounter + 5
-			}
-			bios.IntHandler(code)
-			setRegister(0x001a, next)
-			Log("int " + fmt.Sprintf("0x%08x", code))
-			stall(34)
-		case 0x05:
-			// JNZ
-			// jnz <mode (01 or 02)> <check register> <loc (register or raw addr)>
-			mode := Memory[ProgramCounter+1]
-			checkRegister := Memory[ProgramCounter+2]
-			var loc uint32 = 0
-			var not uint32 = 0
-
-			if mode == 0x01 {	
-				if types.Bits32 == false {
-					loc = uint32(uint16(Memory[ProgramCounter + 3]) << 8 | uint16(Memory[ProgramCounter + 4]))
-					not = ProgramCounter + 5
-				} else {
-					loc = uint32(Memory[ProgramCounter + 3]) << 24 | uint32(Memory[ProgramCounter + 4])	<< 16 | uint32(Memory[ProgramCounter + 5]) << 8 | uint32(Memory[ProgramCounter + 6])
-					not = ProgramCounter + 7
-				}	
-				Log("jnz " + getRegisterName(uint32(checkRegister)) + ", " + fmt.Sprintf("0x%08x", loc))
-			} else if mode == 0x02 {
-				frm := uint32(Memory[ProgramCounter+3])
-				loc = getRegister(frm)
-				not = ProgramCounter + 4
-				Log("jnz " + getRegisterName(uint32(checkRegister)) + ", " + getRegisterName(frm))
-			}
-
-			if getRegister(uint32(checkRegister)) != 0 {
-				setRegister(0x001a, loc)
-			} else {
-				setRegister(0x001a, not)
-			}
-			stall(8)
-		case 0x06:
-			// NOP
-			setRegister(0x001a, ProgramCounter+1)
-			Log("nop")
-			stall(1)
-		case 0x07:
-			// CMP
-			// Syntax: CMP <to> <r1> <r2>
-			to := Memory[ProgramCounter+1]
-			first := Memory[ProgramCounter+2]
-			second := Memory[ProgramCounter+3]
-			Log("cmp " + getRegisterName(uint32(to)) + ", " + getRegisterName(first) + ", " + getRegisterName(second))
-
-			if getRegister(uint32(first)) == getRegister(uint32(second)) {
-				setRegister(uint32(to), uint32(1))
-			} else {
-				setRegister(uint32(to), uint32(0))
-			}
-			setRegister(0x001a, ProgramCounter+4)
-			stall(4)
-		case 0x08:
-			// JZ
-			// jz <mode (01 or 02)> <check register> <loc (register or raw addr)>
-			mode := Memory[ProgramCounter+1]
-			checkRegister := Memory[ProgramCounter+2]
-			var loc uint32 = 0
-			var not uint32 = 0
-
-			if mode == 0x01 {	
-				if types.Bits32 == false {
-					loc = uint32(uint16(Mapper(ProgramCounter + 3)) << 8 | uint16(Mapper(ProgramCounter + 4)))
-					not = ProgramCounter + 5
-				} else {
-					loc = uint32(Mapper(ProgramCounter + 3)) << 24 | uint32(Mapper(ProgramCounter + 4))	<< 16 | uint32(Mapper(ProgramCounter + 5)) << 8 | uint32(Mapper(ProgramCounter + 6))
-					not = ProgramCounter + 7
-				}	
-				Log("jz " + getRegisterName(checkRegister) + ", " + fmt.Sprintf("0x%08x", loc))
-			} else if mode == 0x02 {
-				frm := uint32(Memory[ProgramCounter+3])
-				loc = getRegister(frm)
-				not = ProgramCounter + 4
-				Log("jz " + getRegisterName(checkRegister) + ", " + getRegisterName(frm))
-			}
-
-			if getRegister(uint32(checkRegister)) == 0 {
-				setRegister(0x001a, loc)
-			} else {
-				setRegister(0x001a, not)
-			}
-			stall(8)
-		case 0x09:
-			// INC
-			// inc <register>
-			register := uint32(Memory[ProgramCounter+1])
-			setRegister(register, getRegister(register)+1)
-			setRegister(0x001a, ProgramCounter+2)
-			Log("inc " + getRegisterName(register))
-			stall(1)
-		case 0x0a:
-			// DEC
-			// dec <register>
-			register := uint32(Memory[ProgramCounter+1])
-			setRegister(register, getRegister(register)-1)
-			setRegister(0x001a, ProgramCounter+2)
-			Log("dec " + getRegisterName(register))
-			stall(1)
-		case 0x0b:
-			// PUSH
-			// push <mode> <immediate or register>
-			mode := Memory[ProgramCounter + 1]
-			var value uint32	
-			if mode == 0x1 {	
-				var next uint32 = 0
-				if types.Bits32 == false {
-					value = uint32(uint16(Mapper(ProgramCounter + 2)) << 8 | uint16(Mapper(ProgramCounter + 3)))
-					next = ProgramCounter + 4
-				} else {
-					value = uint32(Mapper(ProgramCounter + 2)) << 24 | uint32(Mapper(ProgramCounter + 3))	<< 16 | uint32(Mapper(ProgramCounter + 4)) << 8 | uint32(Mapper(ProgramCounter + 5))
-					next = ProgramCounter + 6
-				}	
-				setRegister(0x001a, next)
-				Log("push " + fmt.Sprintf("0x%08x", value))
-			} else if mode == 0x2 {
-				value = getRegister(uint32(Mapper(ProgramCounter + 2)))
-				setRegister(0x001a, ProgramCounter + 3)
-				Log("push " + getRegisterName(uint32(Mapper(ProgramCounter + 2))))
-			}	
-			sp := getRegister(0x0019)
-			if types.Bits32 == false {
-				sp = video.Clamp(sp - 2, 0, MEMCAP)
-			} else {
-				sp = video.Clamp(sp - 4, 0, MEMCAP)
-			}
-			Memory[MapperIndex(sp)] = byte(value & 0xFF)
-			Memory[MapperIndex(sp + 1)] = byte(value >> 8)	
-			setRegister(0x0019, uint32(sp))	
-			stall(2)
-		case 0x0c:
-			// POP
-			// pop <register>	
-			register := Mapper(ProgramCounter + 1)
-			sp := getRegister(0x0019)
-			var value uint32
-			if types.Bits32 == false {
-				value = uint32(uint16(Mapper(sp)) | uint16(Mapper(sp + 1)) << 8) 
-			} else {	
-				value = uint32(Mapper(sp)) | uint32(Mapper(sp + 1)) << 8 | uint32(Mapper(sp + 2)) << 16 | uint32(Mapper(sp + 3))
-			}
-			Log("value: " + fmt.Sprintf("0x%08x", value))
-			setRegister(uint32(register), uint32(value))
-			if types.Bits32 == false {
-				sp = video.Clamp(sp + 2, 0, MEMCAP)
-			} else {
-				sp = video.Clamp(sp + 4, 0, MEMCAP)
-			}
-			setRegister(0x0019, uint32(sp))
-			setRegister(0x001a, ProgramCounter + 2)
-			Log("pop " + getRegisterName(register))
-			stall(2)
-		case 0x0d:
-			// ADD
-			// add <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone))+getRegister(uint32(regtwo)))
-			setRegister(0x001a, ProgramCounter+4)
-			Log("add " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(7)
-		case 0x0e:
-			// SUB
-			// SUB <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone))-getRegister(uint32(regtwo)))
-			setRegister(0x001a, ProgramCounter+4)
-			Log("sub " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(7)
-		case 0x0f:
-			// MUL
-			// mul <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone))*getRegister(uint32(regtwo)))
-			setRegister(0x001a, ProgramCounter+4)
-			Log("mul " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(70)
-		case 0x10:
-			// DIV
-			// div <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone))/getRegister(uint32(regtwo)))
-			setRegister(0x001a, ProgramCounter+4)
-			Log("div " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(140)
-		case 0x11:
-			// IGT
-			// igt <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			if getRegister(uint32(regone)) > getRegister(uint32(regtwo)) {
-				setRegister(uint32(toregister), uint32(1))
-			} else {
-				setRegister(uint32(toregister), uint32(0))
-			}
-			setRegister(0x001a, ProgramCounter + 4)
-			Log("igt " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(4)
-		case 0x12:
-			// ILT
-			// ilt <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			if getRegister(uint32(regone)) < getRegister(uint32(regtwo)) {
-				setRegister(uint32(toregister), uint32(1))
-			} else {
-				setRegister(uint32(toregister), uint32(0))
-			}
-			setRegister(0x001a, ProgramCounter + 4)
-			Log("ilt " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(4)
-		case 0x13:
-			// AND
-			// and <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone)) & getRegister(uint32(regtwo)))	
-			setRegister(0x001a, ProgramCounter + 4)
-			Log("and " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(1)
-		case 0x14:
-			// OR
-			// or <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone)) | getRegister(uint32(regtwo)))	
-			setRegister(0x001a, ProgramCounter + 4)
-			Log("or " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(1)
-		case 0x15:
-			// NOR
-			// nor <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), ^(getRegister(uint32(regone)) | getRegister(uint32(regtwo))))	
-			setRegister(0x001a, ProgramCounter + 4)
-			Log("nor " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(3)
-		case 0x16:
-			// NOT
-			// not <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			setRegister(uint32(uint32(toregister)), ^getRegister(uint32(regone)))	
-			setRegister(0x001a, ProgramCounter + 3)
-			Log("not " + getRegisterName(toregister) + ", " + getRegisterName(regone))
-			stall(1)
-		case 0x17:
-			// XOR
-			// xor <register> <register> <register>
-			toregister := Memory[ProgramCounter+1]
-			regone := Memory[ProgramCounter+2]
-			regtwo := Memory[ProgramCounter+3]
-			setRegister(uint32(toregister), getRegister(uint32(regone)) ^ getRegister(uint32(regtwo)))	
-			setRegister(0x001a, ProgramCounter + 4)
-			Log("xor " + getRegisterName(toregister) + ", " + getRegisterName(regone) + ", " + getRegisterName(regtwo))
-			stall(6)
-		case 0x18:
-			// LOD
-			// lod <addr (register)> <destination register>	
-			addr := getRegister(uint32(Memory[ProgramCounter+1]))
-			toregister := uint32(Memory[ProgramCounter+2])
-			setRegister(toregister, uint32(Memory[video.Clamp(addr, 0, MEMCAP)]))
-			setRegister(0x001a, ProgramCounter + 3)
-			Log("lod " + getRegisterName(uint32(Memory[ProgramCounter + 1])) + ", " + getRegisterName(toregister))
-			stall(100)
-		case 0x19:
-			// STR
-			// str <addr (register)> <value (register)>	
-			addr := getRegister(uint32(Memory[ProgramCounter+1]))
-			value := uint32(Memory[ProgramCounter+2])
-			if types.Bits32 == false {
-				Memory[video.Clamp(addr, 0, MEMCAP)] = byte(getRegister(value) >> 8)
-				Memory[video.Clamp(addr + 1, 0, MEMCAP)] = byte(getRegister(value) & 0xFF)
-			} else {
-				Memory[video.Clamp(addr, 0, MEMCAP)] = byte(getRegister(value) >> 24)
-				Memory[video.Clamp(addr + 1, 0, MEMCAP)] = byte(getRegister(value) >> 16)
-				Memory[video.Clamp(addr + 2, 0, MEMCAP)] = byte(getRegister(value) >> 8)
-				Memory[video.Clamp(addr + 3, 0, MEMCAP)] = byte(getRegister(value) & 0xFF)
-			}	
-			setRegister(0x001a, ProgramCounter + 3)
-			Log("str " + getRegisterName(uint32(Memory[ProgramCounter + 1])) + ", " + getRegisterName(value))
-			stall(100)
-		case 0x1a:
-			// LODF
-			// lodf <addr (register)> <destination register>
-			addr := getRegister(uint32(Memory[ProgramCounter+1]))
-			toregister := uint32(Memory[ProgramCounter+2])
-			if types.Bits32 == false {
-				setRegister(toregister, uint32(uint16(Memory[video.Clamp(addr, 0, MEMCAP)]) << 8 | uint16(Memory[video.Clamp(addr + 1, 0, MEMCAP)])))
-			} else {
-				setRegister(toregister, uint32(Memory[video.Clamp(addr, 0, MEMCAP)]) << 24 | uint32(Memory[video.Clamp(addr + 1, 0, MEMCAP)]) << 16 | uint32(Memory[video.Clamp(addr + 2, 0, MEMCAP)]) << 8 | uint32(Memory[video.Clamp(addr + 3, 0, MEMCAP)]) << 16)
-			}
-			setRegister(0x001a, ProgramCounter + 3)
-			Log("lodw " + getRegisterName(uint32(Memory[ProgramCounter + 1])) + ", " + getRegisterName(toregister))
-			stall(100)
-		case 0x1b:
-			// SET
-			// set <00 or 01>
-			mode := uint32(Memory[ProgramCounter + 1])
-			if mode == 0 {
-				types.Bits32 = false
-				Log("16 bit mode")
-			} else if mode == 1 {
-				types.Bits32 = true
-				Log("32 bit mode")
-			}
-			setRegister(0x001a, ProgramCounter + 2)
-		default:
-			setRegister(0x0001, uint32(op))
-			Log("\033[31mIllegal instruction 0x" + fmt.Sprintf("%08x", uint32(op)) + "\033[33m")
-			bios.IntHandler(0x7)	
-			if Debug == true {
-				setRegister(0x001a, ProgramCounter + 1)
-			} else {
-				return
-			}
-		}
-
-		if Debug == true {
-			bufio.NewReader(os.Stdin).ReadBytes('\n')
-		}
+func LoadROM(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("luna-l2: could not open BIOS ROM '" + path + "'")
+		os.Exit(1)
 	}
+	copy(cpu.Memory[:types.MemorySize], data)
 }
 
 // Frontend code
 var Ready bool = false
 func WindowManage(window *app.Window) error {
 	var ops op.Ops
-	img := image.NewRGBA(image.Rect(0, 0, 320, 200))
+	fullscreen := Machine.Fullscreen
 
 	video.InitializePalette()	
 	// Init framebuffer
-	i := 0
-	for y := 0; y < 200; y++ {
-		for x := 0; x < 320; x++ {
-			img.Set(x, y, video.Palette[uint8(video.MemoryVideo[i])])
-			i++
-		}
-	}
+	frame := video.Render(video.View())
+	video.Composite(frame)
+	video.DrawCursor(frame)
 
-	tex := paint.NewImageOp(img)
+	tex := paint.NewImageOp(frame)
 	tex.Filter = paint.FilterNearest
 
 	for {
 		switch E := window.Event().(type) {
 		case app.DestroyEvent:
-			os.Exit(0)
+			shutdown("", 0)
+		case app.ConfigEvent:
+			// The window manager can leave fullscreen too
+			fullscreen = E.Config.Mode == app.Fullscreen
 		case app.FrameEvent:	
 			GTX := app.NewContext(&ops, E)
 
-			paint.Fill(GTX.Ops, color.NRGBA{R: 0, G: 0, B: 0, A: 255})
+			paint.Fill(GTX.Ops, display.Border)
 		
 			area := clip.Rect{Max: GTX.Constraints.Max}.Push(GTX.Ops)
 			event.Op(GTX.Ops, window)
@@ -605,7 +98,42 @@ func WindowManage(window *app.Window) error {
 				}
 				switch event := event.(type) {
 				case key.Event:
-					if event.State == key.Press {
+					// F11 switches between the window and fullscreen
+					if event.State == key.Press && event.Name == key.NameF11 {
+						fullscreen = fullscreen == false
+						if fullscreen == true {
+							window.Option(app.Fullscreen.Option())
+						} else {
+							window.Option(app.Windowed.Option())
+						}
+						continue
+					}
+					// F12 saves a screenshot, it never reaches the program
+					if event.State == key.Press && event.Name == key.NameF12 {
+						path := "luna-l2-" + time.Now().Format("20060102-150405") + ".png"
+						if err := capture.Screenshot(path); err != nil {
+							fmt.Println("luna-l2: could not write screenshot: " + err.Error())
+						} else {
+							fmt.Println("luna-l2: saved " + path)
+						}
+						continue
+					}
+					// Page Up and Page Down scroll through the scrollback
+					if event.State == key.Press && (event.Name == key.NamePageUp || event.Name == key.NamePageDown) {
+						if event.Name == key.NamePageUp {
+							video.Scroll(video.Modes[video.ActiveMode].Rows)
+						} else {
+							video.Scroll(-video.Modes[video.ActiveMode].Rows)
+						}
+						continue
+					}
+					if event.State == key.Press && Machine.Devices.Keyboard == true {
+						// Typing goes back to the live screen
+						video.Scroll(-video.ScrollbackSize)
+						if code, ok := Machine.Keys[string(event.Name)]; ok == true {
+							cpu.Key(code)
+							continue
+						}
 						char := string(event.Name)
 
 						if event.Name == "Space" {
@@ -627,38 +155,40 @@ func WindowManage(window *app.Window) error {
 							char = keyboard.Upper(char)
 						}
 	
-    					setRegister(0x001b, uint32(rune(char[0])))
-    					bios.IntHandler(bios.KeyInterruptCode)
+    					cpu.Key(uint32(rune(char[0])))
 					}
 				}
 			}
 			area.Pop()
 
-			i := 0
-			for y := 0; y < 200; y++ {
-				for x := 0; x < 320; x++ {
-					i = video.Clamp(i, 0, 63999)	
-					img.Set(x, y, video.Palette[video.MemoryVideo[i]])
-					i++
-				}
-			}
+			// The size of the frame depends on the video mode
+			frame = video.Render(video.View())
+			video.Composite(frame)
+			video.DrawCursor(frame)
 
-			tex = paint.NewImageOp(img)
-			tex.Filter = paint.FilterNearest
-
-			scaleX := float32(GTX.Constraints.Max.X) / float32(320)
-			scaleY := float32(GTX.Constraints.Max.Y) / float32(200)
-
-			scale := scaleX
-			if scaleY < scaleX {
-				scale = scaleY
+			// The CRT filter draws a larger picture, which is scaled smoothly
+			var picture image.Image = frame
+			if display.CRT == true {
+				picture = display.Filter(frame)
 			}
-			defer op.Affine(f32.Affine2D{}.Scale(f32.Pt(0, 0), f32.Pt(scale, scale))).Push(GTX.Ops).Pop()
+			tex = paint.NewImageOp(picture)
+			if display.CRT == true {
+				tex.Filter = paint.FilterLinear
+			} else {
+				tex.Filter = paint.FilterNearest
+			}
+
+			place := display.Place(frame.Rect.Size(), GTX.Constraints.Max)
+			size := picture.Bounds().Size()
+			scale := f32.Pt(float32(place.Dx()) / float32(size.X), float32(place.Dy()) / float32(size.Y))
+			transform := op.Affine(f32.Affine2D{}.Scale(f32.Pt(0, 0), scale).Offset(f32.Pt(float32(place.Min.X), float32(place.Min.Y)))).Push(GTX.Ops)
 			tex.Add(GTX.Ops)
-			paint.PaintOp{}.Add(GTX.Ops)	
+			paint.PaintOp{}.Add(GTX.Ops)
+			transform.Pop()
 			E.Frame(GTX.Ops)
 			Ready = true
-			time.Sleep(time.Duration(150) * time.Millisecond)
+			// Repaint after the next vblank, so the window shows whole frames
+			video.WaitFrame(time.Duration(150) * time.Millisecond)
 			window.Invalidate()
 		}
 	}
@@ -667,11 +197,19 @@ func WindowManage(window *app.Window) error {
 
 func InitializeWindow() {
 	go func() {
+		// The 320x200 mode is shown at 320x240 with aspect correction
+		height := 200
+		if Machine.Aspect == true {
+			height = 240
+		}
 		w := new(app.Window)
 		w.Option(
 			app.Title("Luna L2"),
-			app.Size(640, 400),
+			app.Size(unit.Dp(320 * Machine.Scale), unit.Dp(height * Machine.Scale)),
 		)
+		if Machine.Fullscreen == true {
+			w.Option(app.Fullscreen.Option())
+		}
 		if err := WindowManage(w); err != nil {
 			fmt.Println("luna-l2: Failed to initialize window.", 255, 0)
 			os.Exit(1)
@@ -680,56 +218,428 @@ func InitializeWindow() {
 	app.Main()
 }
 
-func main() {
-	bios.Registers = &Registers
-	bios.Memory = &Memory
-	go func() {
-		if Ready == false {	
-			for {
-				if Ready == true {
-					break
-				} else {
-					time.Sleep(500)
-				}
+// Reads the command line on top of the --config profile
+func ParseArgs() {
+	// The profile is loaded first so the other flags override it
+	for i := 1; i < len(os.Args); i++ {
+		if os.Args[i] == "--config" && i + 1 < len(os.Args) {
+			machine, err := config.Load(os.Args[i + 1])
+			if err != nil {
+				fmt.Println("luna-l2: could not load config '" + os.Args[i + 1] + "': " + err.Error())
+				os.Exit(1)
+			}
+			Machine = machine
+		}
+	}
+
+	dump := false
+	boot := ""
+	extra := []string {}
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "--config":
+			i++
+		case "--dump-config":
+			dump = true
+		case "--speed":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --speed"); i++; continue }
+			speed, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid clock speed")
+				i++
+				continue
+			}
+			Machine.Speed = int64(speed)
+			i++
+		case "--memory":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --memory"); i++; continue }
+			size, err := strconv.ParseUint(os.Args[i + 1], 0, 32)
+			if err != nil {
+				fmt.Println("Invalid memory size")
+				i++
+				continue
+			}
+			Machine.Memory = uint32(size)
+			i++
+		case "--disk":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --disk"); i++; continue }
+			extra = append(extra, os.Args[i + 1])
+			i++
+		case "--bios":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --bios"); i++; continue }
+			Machine.BIOS = os.Args[i + 1]
+			i++
+		case "--font":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --font"); i++; continue }
+			Machine.Font = os.Args[i + 1]
+			i++
+		case "--scale":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --scale"); i++; continue }
+			scale, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid scale")
+				i++
+				continue
+			}
+			Machine.Scale = int(scale)
+			i++
+		case "--integer-scale":
+			Machine.IntegerScale = true
+		case "--aspect":
+			Machine.Aspect = true
+		case "--fullscreen":
+			Machine.Fullscreen = true
+		case "--crt":
+			Machine.CRT = true
+		case "--border":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --border"); i++; continue }
+			Machine.Border = os.Args[i + 1]
+			i++
+		case "--headless":
+			Machine.Headless = true
+		case "--frame-rate":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --frame-rate"); i++; continue }
+			rate, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid frame rate")
+				i++
+				continue
+			}
+			Machine.FrameRate = int(rate)
+			i++
+		case "--terminal":
+			Machine.Terminal = true
+		case "--terminal-columns":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --terminal-columns"); i++; continue }
+			columns, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid column count")
+				i++
+				continue
+			}
+			Machine.TerminalColumns = int(columns)
+			i++
+		case "--terminal-rate":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --terminal-rate"); i++; continue }
+			rate, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid refresh rate")
+				i++
+				continue
+			}
+			Machine.TerminalRate = int(rate)
+			i++
+		case "--cores":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --cores"); i++; continue }
+			cores, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid core count")
+				i++
+				continue
+			}
+			Machine.Cores = int(cores)
+			i++
+		case "--sched":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --sched"); i++; continue }
+			Machine.Scheduler = os.Args[i + 1]
+			i++
+		case "--seed":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --seed"); i++; continue }
+			seed, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid seed")
+				i++
+				continue
 			}
+			Machine.Seed = seed
+			i++
+		case "--max-instructions":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --max-instructions"); i++; continue }
+			count, err := strconv.ParseUint(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid instruction limit")
+				i++
+				continue
+			}
+			Machine.MaxInstructions = count
+			i++
+		case "--max-cycles":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --max-cycles"); i++; continue }
+			count, err := strconv.ParseUint(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid cycle limit")
+				i++
+				continue
+			}
+			Machine.MaxCycles = count
+			i++
+		case "--timeout":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --timeout"); i++; continue }
+			Machine.Timeout = os.Args[i + 1]
+			i++
+		case "--record":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --record"); i++; continue }
+			RecordPath = os.Args[i + 1]
+			i++
+		case "--replay":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --replay"); i++; continue }
+			ReplayPath = os.Args[i + 1]
+			i++
+		case "--history":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --history"); i++; continue }
+			steps, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
+			if err != nil || steps < 0 {
+				fmt.Println("Invalid history size")
+				i++
+				continue
+			}
+			Machine.History = int(steps)
+			i++
+		case "--screenshot-at":
+			if i + 2 >= len(os.Args) { fmt.Println("Not enough arguments to --screenshot-at"); i += 2; continue }
+			cycle, err := strconv.ParseUint(os.Args[i + 1], 0, 64)
+			if err != nil {
+				fmt.Println("Invalid cycle count")
+				i += 2
+				continue
+			}
+			capture.ScreenshotAt = cycle
+			capture.ScreenshotPath = os.Args[i + 2]
+			i += 2
+		case "--record-video":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --record-video"); i++; continue }
+			capture.VideoPath = os.Args[i + 1]
+			i++
+		case "--video-interval":
+			if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --video-interval"); i++; continue }
+			interval, err := strconv.ParseUint(os.Args[i + 1], 0, 32)
+			if err != nil || interval < 10 {
+				fmt.Println("Invalid video interval, must be at least 10 ms")
+				i++
+				continue
+			}
+			VideoInterval = interval
+			i++
+		case "--log":
+			Machine.Log = true
+		case "--debug":
+			Machine.Debug = true
+		default:
+			boot = arg
 		}
+	}
 
-		bios.Splash()
+	// A disk image on the command line replaces the boot disk of the profile
+	if boot != "" {
+		if len(Machine.Disks) == 0 {
+			Machine.Disks = []string {boot}
+		} else {
+			Machine.Disks[0] = boot
+		}
+	}
+	Machine.Disks = append(Machine.Disks, extra...)
 
-		if bios.CheckArgs() == false {
-			return
+	if Machine.Speed < 0 {
+		fmt.Println("Invalid clock speed")
+		Machine.Speed = config.Default().Speed
+	}
+	if Machine.Memory < 0x10000 || Machine.Memory > cpu.MEMSIZE {
+		fmt.Println("Invalid memory size, must be between 0x10000 and " + fmt.Sprintf("0x%08x", cpu.MEMSIZE))
+		Machine.Memory = config.Default().Memory
+	}
+	if Machine.Cores < 1 || Machine.Cores > cpu.MaxCores {
+		fmt.Println("Invalid core count, must be between 1 and " + strconv.Itoa(cpu.MaxCores))
+		Machine.Cores = config.Default().Cores
+	}
+	if Machine.Scheduler != "rr" && Machine.Scheduler != "random" {
+		fmt.Println("Invalid scheduler, must be rr or random")
+		Machine.Scheduler = config.Default().Scheduler
+	}
+	if Machine.Scale < 1 {
+		fmt.Println("Invalid scale")
+		Machine.Scale = config.Default().Scale
+	}
+	if Machine.FrameRate < 1 || Machine.FrameRate > 1000 {
+		fmt.Println("Invalid frame rate, must be between 1 and 1000")
+		Machine.FrameRate = config.Default().FrameRate
+	}
+	if video.TerminalColumns(Machine.TerminalColumns) == false {
+		fmt.Println("Invalid terminal width, must divide 320 (such as 40, 80 or 160)")
+		Machine.TerminalColumns = config.Default().TerminalColumns
+	}
+	if Machine.TerminalRate < 1 || Machine.TerminalRate > 100 {
+		fmt.Println("Invalid terminal refresh rate, must be between 1 and 100")
+		Machine.TerminalRate = config.Default().TerminalRate
+	}
+
+	if _, err := parseTimeout(Machine.Timeout); err != nil {
+		fmt.Println("Invalid timeout, must be seconds or a duration such as 1m30s")
+		Machine.Timeout = ""
+	}
+
+	if dump == true {
+		fmt.Println(config.Dump(Machine))
+		os.Exit(0)
+	}
+}
+
+// Plain numbers are seconds
+func parseTimeout(text string) (time.Duration, error) {
+	if text == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseUint(text, 10, 32); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(text)
+}
+
+func ApplyConfig() {
+	cpu.ClockSpeed = Machine.Speed
+	types.MemorySize = Machine.Memory
+	cpu.CoreCount = Machine.Cores
+	cpu.Scheduler = Machine.Scheduler
+	if Machine.Seed != 0 {
+		cpu.Seed = Machine.Seed
+	}
+	cpu.LogOn = Machine.Log || Machine.Debug
+	cpu.Debug = Machine.Debug
+	cpu.History = Machine.Debug
+	cpu.HistorySize = Machine.History
+	cpu.Headless = Machine.Headless
+	cpu.MaxInstructions = Machine.MaxInstructions
+	cpu.MaxCycles = Machine.MaxCycles
+	cpu.Timeout, _ = parseTimeout(Machine.Timeout)
+	bios.Disks = Machine.Disks
+	bios.Devices = Machine.Devices
+	terminal.Enabled = Machine.Terminal
+	terminal.Columns = Machine.TerminalColumns
+	terminal.Rate = Machine.TerminalRate
+	display.IntegerScale = Machine.IntegerScale
+	display.Aspect = Machine.Aspect
+	display.CRT = Machine.CRT
+	if Machine.Border != "" {
+		border, err := display.ParseColour(Machine.Border)
+		if err != nil {
+			fmt.Println("luna-l2: " + err.Error())
+			os.Exit(1)
 		}
-	
-		for i := 1; i < len(os.Args); i++ {
-			arg := os.Args[i]
-			switch arg {
-			case "--speed":
-				if i + 1 >= len(os.Args) { fmt.Println("Not enough arguments to --speed"); i++; continue }
-				speed, err := strconv.ParseInt(os.Args[i + 1], 0, 64)
-				if err != nil {
-					fmt.Println("Invalid clock speed")
-					i++
-					continue
-				}
-				ClockSpeed = int64(speed)
-				i++
-			case "--log":
-				LogOn = true
-			case "--debug":
-				Debug = true
-				LogOn = true
-			default:
-				Filename = arg
+		display.Border = border
+	}
+	if Machine.Font != "" {
+		if err := font.Load(Machine.Font); err != nil {
+			fmt.Println("luna-l2: could not load font '" + Machine.Font + "': " + err.Error())
+			os.Exit(1)
+		}
+	}
+	// The replay brings its own seed, which the new recording then keeps
+	if ReplayPath != "" {
+		if err := cpu.LoadReplay(ReplayPath); err != nil {
+			fmt.Println("luna-l2: could not load session '" + ReplayPath + "': " + err.Error())
+			os.Exit(1)
+		}
+	}
+	if RecordPath != "" {
+		if err := cpu.Record(RecordPath); err != nil {
+			fmt.Println("luna-l2: could not record session to '" + RecordPath + "': " + err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// Frames are spaced in emulated time, so an unthrottled machine is
+	// recorded and refreshed as if it ran at the default speed
+	speed := uint64(cpu.ClockSpeed)
+	if speed == 0 {
+		speed = uint64(config.Default().Speed)
+	}
+	cpu.FrameCycles = max(speed / uint64(Machine.FrameRate), 1)
+	capture.FrameCycles = VideoInterval * speed / 1000
+	capture.FrameDelay = int(VideoInterval / 10)
+}
+
+func run() {
+	if Ready == false {	
+		for {
+			if Ready == true {
+				break
+			} else {
+				time.Sleep(500)
 			}
 		}
+	}
 
-		if Filename == "" {
-			bios.WriteLine("No bootable device", 255, 0)
-			return
-		}	
+	terminal.Start()
+	bios.Splash()
 
+	if Machine.BIOS != "" {
+		LoadROM(Machine.BIOS)
+	} else if len(Machine.Disks) == 0 {
+		bios.WriteLine("No bootable device", 255, 0)
+		if cpu.Headless == true {
+			fmt.Println("luna-l2: no bootable device")
+		}
+		return
+	} else {
 		LoadSector(0, true)	
-		execute()
-	}()	
+	}
+	cpu.InitializeCores()
+	finished := make(chan bool)
+	var timer *time.Timer
+	if cpu.Timeout != 0 {
+		timer = time.AfterFunc(cpu.Timeout, func() {
+			cpu.TimedOut.Store(true)
+			// A program blocked in a BIOS call never gets back to cpu.Execute(),
+			// otherwise cpu.Execute() returns within the second and reports it
+			// itself
+			select {
+			case <-finished:
+			case <-time.After(time.Second):
+				shutdown("timeout reached", cpu.ExitLimit)
+			}
+		})
+	}
+	cpu.Execute()
+	// The window can stay open after the program stops, which must not end in
+	// a timeout later
+	if timer != nil {
+		timer.Stop()
+	}
+	close(finished)
+	if cpu.Limit != "" {
+		shutdown(cpu.Limit, cpu.ExitLimit)
+	}
+}
+
+var stopping sync.Once
+
+// Reports why the machine stopped, if it was a limit, stops the terminal view,
+// finishes the captures and exits. Only the first caller does it, so a limit
+// and the timeout at the same time report once.
+func shutdown(reason string, status int) {
+	stopping.Do(func() {
+		terminal.Stop()
+		if reason != "" {
+			cpu.Report(reason)
+		}
+		capture.Finish()
+		os.Exit(status)
+	})
+}
+
+func main() {
+	cpu.Connect()
+	cpu.Clock = capture.Tick
+	video.InitializePalette()
+
+	ParseArgs()
+	ApplyConfig()
+
+	if cpu.Headless == true {
+		Ready = true
+		run()
+		shutdown("", 0)
+	}
+	go run()
 	InitializeWindow()
 }
@@ -0,0 +1,74 @@
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"time"
+	"luna_l2/video"
+)
+
+// Draws the screen in the terminal Rate times a second, for machines without
+// a window. Frames that did not change are not drawn again.
+var Enabled bool = false
+var Columns int = 80
+var Rate int = 10
+var last []byte
+var lastSize image.Rectangle
+var lastPalette [256]color.NRGBA
+var stop chan bool
+var done chan bool
+
+func Start() {
+	if Enabled == false {
+		return
+	}
+	stop = make(chan bool)
+	done = make(chan bool)
+	// Clear the terminal and hide the cursor
+	fmt.Print("\033[2J\033[?25l")
+	go func() {
+		ticker := time.NewTicker(time.Second / time.Duration(Rate))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				draw()
+				done <- true
+				return
+			case <-ticker.C:
+				draw()
+			}
+		}
+	}()
+}
+
+// Draws the last frame and gives the terminal back
+func Stop() {
+	if stop == nil {
+		return
+	}
+	stop <- true
+	<-done
+	stop = nil
+	fmt.Print("\033[0m\033[?25h")
+}
+
+func draw() {
+	// Sprites can move without VRAM changing, so the whole frame is compared
+	frame := video.Render(video.View())
+	video.Composite(frame)
+	if last != nil && frame.Rect == lastSize && bytes.Equal(last, frame.Pix) == true && lastPalette == video.Palette {
+		return
+	}
+	// The view of another mode can have a different size
+	if last != nil && frame.Rect != lastSize {
+		os.Stdout.WriteString("\033[0m\033[2J")
+	}
+	last = frame.Pix
+	lastSize = frame.Rect
+	lastPalette = video.Palette
+	os.Stdout.WriteString("\033[H" + video.Terminal(frame, Columns))
+}
@@ -7,3 +7,6 @@ type Register struct {
 }
 
 var Bits32 bool = false
+
+// Installed memory, addresses past it read the last byte
+var MemorySize uint32 = 0x70000000
@@ -0,0 +1,268 @@
+package video
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ANSI escape sequences
+// PrintChar understands a subset of the VT100/ANSI sequences, enough for text
+// user interfaces: cursor movement and positioning, erasing, colours and
+// saving the cursor. Unknown sequences are dropped. Colours set with SGR
+// replace the ones the program passes for each character until they are reset.
+const (
+	escapeNone = iota
+	escapeStart
+	escapeCSI
+)
+
+var escape int = escapeNone
+var escapeParams string = ""
+
+// Colours set by SGR, -1 uses the colours passed to PrintChar
+var attrFg int = -1
+var attrBg int = -1
+var bold bool = false
+var reverse bool = false
+// ANSI colour of the foreground if it was set with 30-37, for bold
+var ansiFg int = -1
+var savedX int = 0
+var savedY int = 0
+
+// The 8 ANSI colours in the default palette of mode 0, normal and bright. The
+// other modes have them as the first 16 entries.
+var ansiColors = [16]byte {
+	0x00, 0xa0, 0x14, 0xb4, 0x02, 0xa2, 0x16, 0xb6,
+	0x92, 0xe0, 0x1c, 0xfc, 0x03, 0xe3, 0x1f, 0xff,
+}
+
+// Returns true if the character was taken by an escape sequence
+func ansi(ch rune, fg byte, bg byte) bool {
+	switch escape {
+	case escapeNone:
+		if ch == 0x1b {
+			escape = escapeStart
+			return true
+		}
+		return false
+	case escapeStart:
+		escape = escapeNone
+		switch ch {
+		case '[':
+			escape = escapeCSI
+			escapeParams = ""
+		case '7':
+			savedX, savedY = CursorX, CursorY
+		case '8':
+			CursorX, CursorY = savedX, savedY
+		case 'c':
+			resetAttributes()
+			clearCells(0, mode().Columns * mode().Rows, 0)
+			CursorX, CursorY = 0, 0
+		}
+		return true
+	}
+
+	// Parameter and intermediate bytes, then a final byte from 0x40 to 0x7e
+	if ch >= 0x20 && ch <= 0x3f {
+		if len(escapeParams) < 64 {
+			escapeParams += string(ch)
+		}
+		return true
+	}
+	escape = escapeNone
+	if ch < 0x40 || ch > 0x7e || strings.HasPrefix(escapeParams, "?") == true {
+		// Private sequences such as showing the cursor have nothing to do
+		return true
+	}
+	_, background := Colors(fg, bg)
+	csi(ch, params(escapeParams), background)
+	return true
+}
+
+func params(text string) []int {
+	values := []int {}
+	if text == "" {
+		return values
+	}
+	for _, field := range strings.Split(text, ";") {
+		value, err := strconv.Atoi(field)
+		if err != nil || value < 0 {
+			value = 0
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// Parameter i, or def if it is missing or 0
+func param(values []int, i int, def int) int {
+	if i >= len(values) || values[i] == 0 {
+		return def
+	}
+	return values[i]
+}
+
+func csi(final rune, values []int, bg byte) {
+	columns, rows := mode().Columns, mode().Rows
+	switch final {
+	case 'A':
+		CursorY = Clamp(CursorY - param(values, 0, 1), 0, rows - 1)
+	case 'B':
+		CursorY = Clamp(CursorY + param(values, 0, 1), 0, rows - 1)
+	case 'C':
+		CursorX = Clamp(CursorX + param(values, 0, 1), 0, columns - 1)
+	case 'D':
+		CursorX = Clamp(CursorX - param(values, 0, 1), 0, columns - 1)
+	case 'E':
+		CursorY = Clamp(CursorY + param(values, 0, 1), 0, rows - 1)
+		CursorX = 0
+	case 'F':
+		CursorY = Clamp(CursorY - param(values, 0, 1), 0, rows - 1)
+		CursorX = 0
+	case 'G':
+		CursorX = Clamp(param(values, 0, 1) - 1, 0, columns - 1)
+	case 'H', 'f':
+		CursorY = Clamp(param(values, 0, 1) - 1, 0, rows - 1)
+		CursorX = Clamp(param(values, 1, 1) - 1, 0, columns - 1)
+	case 'J':
+		cursor := CursorY * columns + CursorX
+		switch param(values, 0, 0) {
+		case 0:
+			clearCells(cursor, columns * rows, bg)
+		case 1:
+			clearCells(0, cursor + 1, bg)
+		case 2, 3:
+			clearCells(0, columns * rows, bg)
+		}
+	case 'K':
+		start := CursorY * columns
+		cursor := start + CursorX
+		switch param(values, 0, 0) {
+		case 0:
+			clearCells(cursor, start + columns, bg)
+		case 1:
+			clearCells(start, cursor + 1, bg)
+		case 2:
+			clearCells(start, start + columns, bg)
+		}
+	case 'm':
+		sgr(values)
+	case 's':
+		savedX, savedY = CursorX, CursorY
+	case 'u':
+		CursorX, CursorY = savedX, savedY
+	}
+}
+
+// Clears console cells from start up to end, counting across the rows
+func clearCells(start int, end int, bg byte) {
+	for cell := start; cell < end; cell++ {
+		drawCell(cell % mode().Columns, cell / mode().Columns, ' ', bg, bg)
+	}
+}
+
+func sgr(values []int) {
+	if len(values) == 0 {
+		values = []int {0}
+	}
+	for i := 0; i < len(values); i++ {
+		value := values[i]
+		switch {
+		case value == 0:
+			resetAttributes()
+		case value == 1:
+			bold = true
+		case value == 22:
+			bold = false
+		case value == 7:
+			reverse = true
+		case value == 27:
+			reverse = false
+		case value >= 30 && value <= 37:
+			ansiFg = value - 30
+			attrFg = int(ansiColour(ansiFg))
+		case value == 39:
+			ansiFg = -1
+			attrFg = -1
+		case value >= 40 && value <= 47:
+			attrBg = int(ansiColour(value - 40))
+		case value == 49:
+			attrBg = -1
+		case value >= 90 && value <= 97:
+			ansiFg = -1
+			attrFg = int(ansiColour(value - 90 + 8))
+		case value >= 100 && value <= 107:
+			attrBg = int(ansiColour(value - 100 + 8))
+		case value == 38 || value == 48:
+			// 5;n picks a palette entry, 2;r;g;b the closest one
+			color := -1
+			if i + 2 < len(values) && values[i + 1] == 5 {
+				color = values[i + 2] & 0xff
+				i += 2
+			} else if i + 4 < len(values) && values[i + 1] == 2 {
+				r, g, b := values[i + 2] & 0xff, values[i + 3] & 0xff, values[i + 4] & 0xff
+				color = int(closest(r, g, b))
+				i += 4
+			} else {
+				return
+			}
+			if value == 38 {
+				ansiFg = -1
+				attrFg = color
+			} else {
+				attrBg = color
+			}
+		}
+	}
+}
+
+func ansiColour(i int) byte {
+	if ActiveMode == ModeGraphics {
+		return ansiColors[i]
+	}
+	return byte(i)
+}
+
+// Palette entry closest to an RGB colour, out of the ones the mode can show
+func closest(r int, g int, b int) byte {
+	count := 256
+	switch mode().Depth {
+	case 0, 4:
+		count = 16
+	case 1:
+		count = 2
+	}
+	best, distance := 0, -1
+	for i := 0; i < count; i++ {
+		entry := Palette[i]
+		d := square(int(entry.R) - r) + square(int(entry.G) - g) + square(int(entry.B) - b)
+		if distance == -1 || d < distance {
+			best, distance = i, d
+		}
+	}
+	return byte(best)
+}
+
+func resetAttributes() {
+	attrFg, attrBg = -1, -1
+	ansiFg = -1
+	bold, reverse = false, false
+}
+
+// Colours a character is drawn in once the SGR attributes are applied
+func Colors(fg byte, bg byte) (byte, byte) {
+	if attrFg != -1 {
+		fg = byte(attrFg)
+	}
+	if bold == true && ansiFg != -1 {
+		fg = ansiColour(ansiFg + 8)
+	}
+	if attrBg != -1 {
+		bg = byte(attrBg)
+	}
+	if reverse == true {
+		fg, bg = bg, fg
+	}
+	return fg, bg
+}
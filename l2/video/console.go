@@ -0,0 +1,142 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"luna_l2/font"
+	"sync"
+	"time"
+)
+
+// Text console
+// Once the cursor moves past the last row the screen scrolls up by one row and
+// the row that leaves the top is kept in the scrollback, which the window can
+// show with Page Up and Page Down. The program only ever sees MemoryVideo.
+
+var Scrollback = [][]byte {}
+var ScrollbackSize int = 500
+// Rows the window is scrolled back, 0 shows the live screen
+var ScrollOffset int = 0
+var scrollLock sync.Mutex
+
+// Bytes of VRAM in one console row of the current mode
+func rowBytes() int {
+	return mode().Size / mode().Rows
+}
+
+// Moves the cursor to the next row, scrolling if it was on the last one
+func newline() {
+	CursorY++
+	if CursorY < mode().Rows {
+		return
+	}
+	CursorY = mode().Rows - 1
+
+	vram := VRAM()
+	scrollLock.Lock()
+	Scrollback = append(Scrollback, append([]byte {}, vram[:rowBytes()]...))
+	if len(Scrollback) > ScrollbackSize {
+		Scrollback[0] = nil
+		Scrollback = Scrollback[1:]
+	}
+	scrollLock.Unlock()
+
+	copy(vram, vram[rowBytes():])
+	for i := len(vram) - rowBytes(); i < len(vram); i++ {
+		vram[i] = 0
+	}
+}
+
+// Scrolls the window view by a number of rows, positive goes back
+func Scroll(rows int) {
+	scrollLock.Lock()
+	defer scrollLock.Unlock()
+	ScrollOffset = Clamp(ScrollOffset + rows, 0, len(Scrollback))
+}
+
+// What the window shows: the screen, or part of the scrollback followed by
+// the top of the screen while scrolled back, and the mode to draw it in
+func View() ([]byte, int) {
+	scrollLock.Lock()
+	defer scrollLock.Unlock()
+	vram := display()
+	if ScrollOffset == 0 {
+		if DoubleBuffer == true {
+			return bytes.Clone(vram), ActiveMode
+		}
+		return vram, ActiveMode
+	}
+	view := make([]byte, 0, len(vram))
+	for _, row := range Scrollback[len(Scrollback) - ScrollOffset:] {
+		if len(view) == len(vram) {
+			break
+		}
+		view = append(view, row...)
+	}
+	return append(view, vram[:len(vram) - len(view)]...), ActiveMode
+}
+
+// Puts the video device back the way it is at power on: clears the screen,
+// the cursor, the scrollback, the sprites and the frame state
+func Reset() {
+	scrollLock.Lock()
+	defer scrollLock.Unlock()
+	for i := range MemoryVideo {
+		MemoryVideo[i] = 0
+	}
+	ActiveMode = ModeGraphics
+	CursorX = 0
+	CursorY = 0
+	CursorVisible = false
+	InitializePalette()
+	font.Reset()
+	escape = escapeNone
+	resetAttributes()
+	Scrollback = [][]byte {}
+	ScrollOffset = 0
+	SpriteTable = 0
+	SpriteCount = 0
+	Layer = TileLayer{}
+	DoubleBuffer = false
+	flipPending = false
+	FrameCount = 0
+	VBlankMessage = 0
+	VBlankCore = 0
+}
+
+// Moves the cursor, clamped to the screen
+func SetCursor(column int, row int) {
+	CursorX = Clamp(column, 0, mode().Columns - 1)
+	CursorY = Clamp(row, 0, mode().Rows - 1)
+}
+
+// Fills the screen with a colour and moves the cursor to the top left
+func Clear(bg byte) {
+	clearCells(0, mode().Columns * mode().Rows, bg)
+	CursorX = 0
+	CursorY = 0
+}
+
+// Hardware cursor
+// An underline blinking at the cursor position. It is drawn over the view in
+// the window only and never written to VRAM.
+var CursorVisible bool = false
+
+func DrawCursor(img *image.Paletted) {
+	if CursorVisible == false || ScrollOffset != 0 || time.Now().UnixMilli() / 500 % 2 == 1 {
+		return
+	}
+	m := mode()
+	// White in the default palette of the mode
+	var c byte = 0xff
+	switch m.Depth {
+	case 0, 4:
+		c = 15
+	case 1:
+		c = 1
+	}
+	y := CursorY * m.CellHeight + m.CellHeight - 1
+	for x := CursorX * 8; x < CursorX * 8 + 8; x++ {
+		img.SetColorIndex(x, y, c)
+	}
+}
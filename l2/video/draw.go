@@ -0,0 +1,168 @@
+package video
+
+// Drawing
+// Shapes for the graphics modes, drawn with SetPixel so they are clipped to
+// the screen and take colours the way the mode does. Nothing is drawn in the
+// text mode.
+
+// Colour of a pixel on screen, 0 off the screen
+func GetPixel(x int, y int) byte {
+	m := mode()
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return 0
+	}
+	return Pixel(VRAM(), ActiveMode, x, y)
+}
+
+func HLine(x int, y int, length int, c byte) {
+	m := mode()
+	if y < 0 || y >= m.Height {
+		return
+	}
+	for i := max(x, 0); i < x + length && i < m.Width; i++ {
+		SetPixel(i, y, c)
+	}
+}
+
+func VLine(x int, y int, length int, c byte) {
+	m := mode()
+	if x < 0 || x >= m.Width {
+		return
+	}
+	for i := max(y, 0); i < y + length && i < m.Height; i++ {
+		SetPixel(x, i, c)
+	}
+}
+
+// Bresenham's line, both ends included. Ends further than 32767 pixels from
+// the screen are moved along the line to that distance, so a line with 32 bit
+// coordinates does not take billions of steps.
+func Line(x0 int, y0 int, x1 int, y1 int, c byte) {
+	var ok bool
+	if x0, y0, x1, y1, ok = clipLine(x0, y0, x1, y1, -0x8000, 0x7fff); ok == false {
+		return
+	}
+	dx := abs(x1 - x0)
+	dy := -abs(y1 - y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		SetPixel(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := err * 2
+		if e2 >= dy {
+			err += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+// Liang-Barsky clipping of a line to a square, returns false if the line is
+// outside of it
+func clipLine(x0 int, y0 int, x1 int, y1 int, low int, high int) (int, int, int, int, bool) {
+	inside := func(v int) bool {
+		return v >= low && v <= high
+	}
+	if inside(x0) && inside(y0) && inside(x1) && inside(y1) {
+		return x0, y0, x1, y1, true
+	}
+	dx, dy := float64(x1 - x0), float64(y1 - y0)
+	t0, t1 := 0.0, 1.0
+	for _, edge := range [4][2]float64 {
+		{-dx, float64(x0 - low)}, {dx, float64(high - x0)},
+		{-dy, float64(y0 - low)}, {dy, float64(high - y0)},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return 0, 0, 0, 0, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 && t > t0 {
+			t0 = t
+		} else if p > 0 && t < t1 {
+			t1 = t
+		}
+		if t0 > t1 {
+			return 0, 0, 0, 0, false
+		}
+	}
+	return x0 + int(t0 * dx), y0 + int(t0 * dy), x0 + int(t1 * dx), y0 + int(t1 * dy), true
+}
+
+func Rect(x int, y int, width int, height int, c byte, fill bool) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	if fill == true {
+		for i := max(y, 0); i < y + height && i < mode().Height; i++ {
+			HLine(x, i, width, c)
+		}
+		return
+	}
+	HLine(x, y, width, c)
+	HLine(x, y + height - 1, width, c)
+	VLine(x, y, height, c)
+	VLine(x + width - 1, y, height, c)
+}
+
+// Midpoint circle, filled circles are drawn as horizontal spans. The radius
+// can be at most 32767.
+func Circle(cx int, cy int, radius int, c byte, fill bool) {
+	if radius < 0 || radius > 0x7fff {
+		return
+	}
+	x, y := radius, 0
+	err := 1 - radius
+	for x >= y {
+		if fill == true {
+			HLine(cx - x, cy + y, x * 2 + 1, c)
+			HLine(cx - x, cy - y, x * 2 + 1, c)
+			HLine(cx - y, cy + x, y * 2 + 1, c)
+			HLine(cx - y, cy - x, y * 2 + 1, c)
+		} else {
+			for _, point := range [8][2]int {
+				{x, y}, {y, x}, {-y, x}, {-x, y},
+				{-x, -y}, {-y, -x}, {y, -x}, {x, -y},
+			} {
+				SetPixel(cx + point[0], cy + point[1], c)
+			}
+		}
+		y++
+		if err < 0 {
+			err += y * 2 + 1
+		} else {
+			x--
+			err += (y - x) * 2 + 1
+		}
+	}
+}
+
+// Copies an image of one byte per pixel, row after row, to the screen. Pixels
+// of the key colour are skipped, a key of -1 copies every pixel.
+func Blit(pixels []byte, x int, y int, width int, height int, key int) {
+	m := mode()
+	// Only the part on the screen
+	for row := max(0, -y); row < height && y + row < m.Height; row++ {
+		for column := max(0, -x); column < width && x + column < m.Width; column++ {
+			c := pixels[row * width + column]
+			if int(c) != key {
+				SetPixel(x + column, y + row, c)
+			}
+		}
+	}
+}
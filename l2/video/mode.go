@@ -0,0 +1,173 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"luna_l2/font"
+)
+
+// Video modes
+// Every mode keeps its screen at the start of MemoryVideo:
+// 0: 320x200 graphics, one byte per pixel (the default)
+// 1: 80x25 text, two bytes per cell: the character, then the background
+//    colour in the high nibble and the foreground colour in the low one. Each
+//    cell is drawn 8x16 with the 8x8 font doubled vertically, 640x400 in all.
+// 2: 640x480 graphics, 16 colours, two pixels per byte, the left one in the
+//    high nibble
+// 3: 640x480 monochrome, eight pixels per byte, the left one in the high bit
+type Mode struct {
+	Width int
+	Height int
+	// Bits per pixel, 0 for the text mode
+	Depth int
+	// Size of the console
+	Columns int
+	Rows int
+	// Height of a console row in pixels
+	CellHeight int
+	// Bytes of VRAM the mode uses
+	Size int
+}
+
+const (
+	ModeGraphics = 0
+	ModeText = 1
+	Mode16 = 2
+	ModeMono = 3
+)
+
+var Modes = []Mode {
+	{Width: 320, Height: 200, Depth: 8, Columns: 40, Rows: 25, CellHeight: 8, Size: 64000},
+	{Width: 640, Height: 400, Depth: 0, Columns: 80, Rows: 25, CellHeight: 16, Size: 4000},
+	{Width: 640, Height: 480, Depth: 4, Columns: 80, Rows: 60, CellHeight: 8, Size: 153600},
+	{Width: 640, Height: 480, Depth: 1, Columns: 80, Rows: 60, CellHeight: 8, Size: 38400},
+}
+
+var ActiveMode int = ModeGraphics
+
+// Colours of the 16 colour modes by default, the ANSI colours in the same
+// order as the escape sequences use them
+var ansiPalette = [16]color.NRGBA {
+	{0, 0, 0, 255}, {170, 0, 0, 255}, {0, 170, 0, 255}, {170, 85, 0, 255},
+	{0, 0, 170, 255}, {170, 0, 170, 255}, {0, 170, 170, 255}, {170, 170, 170, 255},
+	{85, 85, 85, 255}, {255, 85, 85, 255}, {85, 255, 85, 255}, {255, 255, 85, 255},
+	{85, 85, 255, 255}, {255, 85, 255, 255}, {85, 255, 255, 255}, {255, 255, 255, 255},
+}
+
+func mode() Mode {
+	return Modes[ActiveMode]
+}
+
+// The part of MemoryVideo the current mode uses
+func VRAM() []byte {
+	return MemoryVideo[:mode().Size]
+}
+
+// Switches to a mode, clearing the screen and the scrollback and resetting
+// the palette. Returns false if there is no such mode.
+func SetMode(id int) bool {
+	if id < 0 || id >= len(Modes) {
+		return false
+	}
+	scrollLock.Lock()
+	defer scrollLock.Unlock()
+	ActiveMode = id
+	for i := range MemoryVideo {
+		MemoryVideo[i] = 0
+		Front[i] = 0
+	}
+	CursorX = 0
+	CursorY = 0
+	Scrollback = [][]byte {}
+	ScrollOffset = 0
+	resetAttributes()
+	InitializePalette()
+	return true
+}
+
+// Colour of a pixel of a screen in a mode, as a palette index
+func Pixel(screen []byte, id int, x int, y int) byte {
+	m := Modes[id]
+	switch m.Depth {
+	case 8:
+		return screen[y * m.Width + x]
+	case 4:
+		value := screen[(y * m.Width + x) / 2]
+		if x % 2 == 0 {
+			return value >> 4
+		}
+		return value & 0x0f
+	case 1:
+		return (screen[(y * m.Width + x) / 8] >> (7 - x % 8)) & 1
+	}
+	cell := ((y / m.CellHeight) * m.Columns + x / 8) * 2
+	glyph := font.Font[screen[cell]]
+	if glyph[(y % m.CellHeight) * 8 / m.CellHeight] & byte(1 << (x % 8)) != 0 {
+		return screen[cell + 1] & 0x0f
+	}
+	return screen[cell + 1] >> 4
+}
+
+// Sets a pixel of the current graphics mode, pixels off the screen are
+// ignored. The 16 colour mode uses the low 4 bits of the colour and the
+// monochrome mode draws every colour but 0 as 1.
+func SetPixel(x int, y int, c byte) {
+	m := mode()
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return
+	}
+	switch m.Depth {
+	case 8:
+		MemoryVideo[y * m.Width + x] = c
+	case 4:
+		i := (y * m.Width + x) / 2
+		if x % 2 == 0 {
+			MemoryVideo[i] = MemoryVideo[i] & 0x0f | (c & 0x0f) << 4
+		} else {
+			MemoryVideo[i] = MemoryVideo[i] & 0xf0 | c & 0x0f
+		}
+	case 1:
+		i := (y * m.Width + x) / 8
+		bit := byte(0x80 >> (x % 8))
+		if c != 0 {
+			MemoryVideo[i] |= bit
+		} else {
+			MemoryVideo[i] &^= bit
+		}
+	}
+}
+
+// Draws a character in a console cell of the current mode
+func drawCell(column int, row int, ch rune, fg byte, bg byte) {
+	m := mode()
+	if m.Depth == 0 {
+		cell := (row * m.Columns + column) * 2
+		if ch < 0 || ch > 0xff {
+			ch = 0
+		}
+		MemoryVideo[cell] = byte(ch)
+		MemoryVideo[cell + 1] = bg << 4 | fg & 0x0f
+		return
+	}
+	PushChar(column * 8, row * m.CellHeight, ch, fg, bg)
+}
+
+// Draws a screen in a mode as an image in the current palette
+func Render(screen []byte, id int) *image.Paletted {
+	m := Modes[id]
+	palette := make(color.Palette, len(Palette))
+	for i := range Palette {
+		palette[i] = Palette[i]
+	}
+	img := image.NewPaletted(image.Rect(0, 0, m.Width, m.Height), palette)
+	if m.Depth == 8 {
+		copy(img.Pix, screen)
+		return img
+	}
+	for y := 0; y < m.Height; y++ {
+		for x := 0; x < m.Width; x++ {
+			img.Pix[y * m.Width + x] = Pixel(screen, id, x, y)
+		}
+	}
+	return img
+}
@@ -0,0 +1,184 @@
+package video
+
+import (
+	"image"
+	"luna_l2/types"
+)
+
+// Sprites and tiles
+// Both are drawn over the screen when a frame is shown (in the window,
+// screenshots, videos and the terminal view) and never change VRAM. Their
+// tables and patterns are in main memory, so the program moves a sprite by
+// writing its entry. Pixels are one byte each and 0 is transparent.
+//
+// An entry of the sprite table is 16 bytes, big endian:
+// 0-1: x, 2-3: y (signed)
+// 4: width, 5: height (pixels)
+// 6: flags, see below
+// 7: palette offset, added to the pattern's pixels
+// 8-11: address of the pattern, width * height bytes row after row
+// 12-15: unused
+// Lower entries are drawn over higher ones.
+const (
+	SpriteVisible = 0x01
+	SpriteFlipX = 0x02
+	SpriteFlipY = 0x04
+	// Drawn under the tile layer instead of over it
+	SpriteBehind = 0x08
+)
+
+const MaxSprites int = 64
+
+var Memory *[0x70000000]byte
+// Address of the sprite table, with SpriteCount entries (0 for no sprites)
+var SpriteTable uint32 = 0
+var SpriteCount int = 0
+
+// The tile layer shows a map of 8x8 tiles, repeated in both directions and
+// scrolled by ScrollX and ScrollY pixels. Each byte of the map picks one of
+// 256 tiles, which are 64 bytes each.
+type TileLayer struct {
+	Tiles uint32
+	Map uint32
+	// Size of the map in tiles, 0 turns the layer off
+	Width int
+	Height int
+	ScrollX int
+	ScrollY int
+}
+
+var Layer TileLayer
+
+type sprite struct {
+	X int
+	Y int
+	Width int
+	Height int
+	Flags byte
+	Offset byte
+	Pattern uint32
+}
+
+func read(address uint32) byte {
+	if Memory == nil || address >= types.MemorySize {
+		return 0
+	}
+	return Memory[address]
+}
+
+func sprites() []sprite {
+	list := []sprite {}
+	for i := 0; i < SpriteCount; i++ {
+		entry := SpriteTable + uint32(i) * 16
+		list = append(list, sprite{
+			X: int(int16(uint16(read(entry)) << 8 | uint16(read(entry + 1)))),
+			Y: int(int16(uint16(read(entry + 2)) << 8 | uint16(read(entry + 3)))),
+			Width: int(read(entry + 4)),
+			Height: int(read(entry + 5)),
+			Flags: read(entry + 6),
+			Offset: read(entry + 7),
+			Pattern: uint32(read(entry + 8)) << 24 | uint32(read(entry + 9)) << 16 | uint32(read(entry + 10)) << 8 | uint32(read(entry + 11)),
+		})
+	}
+	return list
+}
+
+// Colour of a sprite at a screen position, false if it is transparent there
+func (s sprite) pixel(x int, y int) (byte, bool) {
+	if s.Flags & SpriteVisible == 0 || x < s.X || y < s.Y || x >= s.X + s.Width || y >= s.Y + s.Height {
+		return 0, false
+	}
+	px, py := x - s.X, y - s.Y
+	if s.Flags & SpriteFlipX != 0 {
+		px = s.Width - 1 - px
+	}
+	if s.Flags & SpriteFlipY != 0 {
+		py = s.Height - 1 - py
+	}
+	value := read(s.Pattern + uint32(py * s.Width + px))
+	if value == 0 {
+		return 0, false
+	}
+	return value + s.Offset, true
+}
+
+// Colour of the tile layer at a screen position, false if it is transparent
+func tilePixel(x int, y int) (byte, bool) {
+	if Layer.Width <= 0 || Layer.Height <= 0 {
+		return 0, false
+	}
+	mx := ((x + Layer.ScrollX) % (Layer.Width * 8) + Layer.Width * 8) % (Layer.Width * 8)
+	my := ((y + Layer.ScrollY) % (Layer.Height * 8) + Layer.Height * 8) % (Layer.Height * 8)
+	tile := read(Layer.Map + uint32((my / 8) * Layer.Width + mx / 8))
+	value := read(Layer.Tiles + uint32(tile) * 64 + uint32((my % 8) * 8 + mx % 8))
+	return value, value != 0
+}
+
+// Draws the tile layer and the sprites over an image of the screen. The text
+// mode and the scrollback have neither.
+func Composite(img *image.Paletted) {
+	if mode().Depth == 0 || ScrollOffset != 0 {
+		return
+	}
+	list := sprites()
+	drawSprites(img, list, true)
+	if Layer.Width > 0 && Layer.Height > 0 {
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				if value, ok := tilePixel(x, y); ok == true {
+					img.Pix[y * img.Stride + x] = value
+				}
+			}
+		}
+	}
+	drawSprites(img, list, false)
+}
+
+func drawSprites(img *image.Paletted, list []sprite, behind bool) {
+	for i := len(list) - 1; i >= 0; i-- {
+		s := list[i]
+		if (s.Flags & SpriteBehind != 0) != behind {
+			continue
+		}
+		for y := max(s.Y, 0); y < s.Y + s.Height && y < img.Rect.Dy(); y++ {
+			for x := max(s.X, 0); x < s.X + s.Width && x < img.Rect.Dx(); x++ {
+				if value, ok := s.pixel(x, y); ok == true {
+					img.Pix[y * img.Stride + x] = value
+				}
+			}
+		}
+	}
+}
+
+// Collision flags of a sprite: bit 0 is set if one of its pixels overlaps a
+// pixel of another visible sprite, bit 1 if it overlaps a pixel of the tile
+// layer. Transparent pixels never collide, positions off the screen do.
+func Collisions(index int) uint32 {
+	list := sprites()
+	if index < 0 || index >= len(list) {
+		return 0
+	}
+	s := list[index]
+	var flags uint32 = 0
+	for y := s.Y; y < s.Y + s.Height; y++ {
+		for x := s.X; x < s.X + s.Width; x++ {
+			if _, ok := s.pixel(x, y); ok == false {
+				continue
+			}
+			for i, other := range list {
+				if i != index && flags & 1 == 0 {
+					if _, ok := other.pixel(x, y); ok == true {
+						flags |= 1
+					}
+				}
+			}
+			if _, ok := tilePixel(x, y); ok == true {
+				flags |= 2
+			}
+			if flags == 3 {
+				return flags
+			}
+		}
+	}
+	return flags
+}
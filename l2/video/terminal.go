@@ -0,0 +1,100 @@
+package video
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Terminal view of the framebuffer
+// Each character cell shows two square blocks of pixels, one above the other,
+// with the upper half block character: the foreground colour is the top block
+// and the background colour the bottom one. A block is the average colour of
+// its pixels, so text stays readable when it is scaled down.
+var cube = [6]int {0, 95, 135, 175, 215, 255}
+
+// Columns the screen can be drawn at, each a whole number of pixels wide in
+// every mode
+func TerminalColumns(columns int) bool {
+	return columns > 0 && columns <= 320 && 320 % columns == 0
+}
+
+// Renders an image of the screen as lines of ANSI text, columns must be
+// accepted by TerminalColumns
+func Terminal(img *image.Paletted, columns int) string {
+	size := img.Rect.Dx() / columns
+	rows := (img.Rect.Dy() + size * 2 - 1) / (size * 2)
+	var text strings.Builder
+	for row := 0; row < rows; row++ {
+		fg, bg := -1, -1
+		for column := 0; column < columns; column++ {
+			top := block(img, column * size, row * size * 2, size)
+			bottom := block(img, column * size, row * size * 2 + size, size)
+			if top != fg {
+				fmt.Fprintf(&text, "\033[38;5;%dm", top)
+				fg = top
+			}
+			if bottom != bg {
+				fmt.Fprintf(&text, "\033[48;5;%dm", bottom)
+				bg = bottom
+			}
+			text.WriteString("▀")
+		}
+		text.WriteString("\033[0m\n")
+	}
+	return text.String()
+}
+
+// Average colour of a block of pixels as a 256 colour terminal index, the
+// part of a block below the screen is left out
+func block(img *image.Paletted, x int, y int, size int) int {
+	r, g, b, count := 0, 0, 0, 0
+	for py := y; py < y + size && py < img.Rect.Dy(); py++ {
+		for px := x; px < x + size; px++ {
+			colour := img.Palette[img.Pix[py * img.Stride + px]].(color.NRGBA)
+			r += int(colour.R)
+			g += int(colour.G)
+			b += int(colour.B)
+			count++
+		}
+	}
+	if count == 0 {
+		return 16
+	}
+	return nearest(r / count, g / count, b / count)
+}
+
+// Closest colour of the 6x6x6 cube or the grey ramp of a 256 colour terminal
+func nearest(r int, g int, b int) int {
+	level := func(value int) int {
+		best := 0
+		for i := range cube {
+			if abs(cube[i] - value) < abs(cube[best] - value) {
+				best = i
+			}
+		}
+		return best
+	}
+	ri, gi, bi := level(r), level(g), level(b)
+	colour := 16 + ri * 36 + gi * 6 + bi
+	distance := square(cube[ri] - r) + square(cube[gi] - g) + square(cube[bi] - b)
+
+	grey := Clamp((r + g + b) / 3 - 8 + 5, 0, 239) / 10
+	value := 8 + grey * 10
+	if square(value - r) + square(value - g) + square(value - b) < distance {
+		colour = 232 + grey
+	}
+	return colour
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func square(x int) int {
+	return x * x
+}
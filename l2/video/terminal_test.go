@@ -0,0 +1,71 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestTerminal(t *testing.T) {
+	// Black on the left half, white on the right
+	img := image.NewPaletted(image.Rect(0, 0, 320, 200), color.Palette{color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}})
+	for y := 0; y < 200; y++ {
+		for x := 160; x < 320; x++ {
+			img.Pix[y * img.Stride + x] = 1
+		}
+	}
+	row := func(columns int, last bool) string {
+		line := "\033[38;5;16m\033[48;5;16m" + strings.Repeat("▀", columns / 2) + "\033[38;5;231m"
+		// The bottom blocks of the last row are below the screen and stay black
+		if last == false {
+			line += "\033[48;5;231m"
+		}
+		return line + strings.Repeat("▀", columns / 2) + "\033[0m"
+	}
+	for _, test := range []struct {
+		columns, rows int
+		last string
+	}{
+		// 8 pixel blocks leave 8 rows for the last line
+		{40, 13, row(40, true)},
+		{80, 25, row(80, false)},
+	} {
+		lines := strings.Split(Terminal(img, test.columns), "\n")
+		if len(lines) != test.rows + 1 || lines[test.rows] != "" {
+			t.Errorf("%d columns: %d rows, want %d", test.columns, len(lines) - 1, test.rows)
+			continue
+		}
+		if lines[0] != row(test.columns, false) {
+			t.Errorf("%d columns: first row = %q", test.columns, lines[0])
+		}
+		if lines[test.rows - 1] != test.last {
+			t.Errorf("%d columns: last row = %q, want %q", test.columns, lines[test.rows - 1], test.last)
+		}
+	}
+}
+
+func TestNearest(t *testing.T) {
+	for _, test := range []struct {
+		r, g, b, want int
+	}{
+		{0, 0, 0, 16},
+		{255, 255, 255, 231},
+		{255, 0, 0, 196},
+		// Cube colours win over a grey that is as close
+		{95, 95, 95, 59},
+		{255, 255, 254, 231},
+		// Greys between the cube levels use the ramp, from 8 to 238 in steps
+		// of 10
+		{8, 8, 8, 232},
+		{12, 12, 12, 232},
+		{13, 13, 13, 233},
+		{128, 128, 128, 244},
+		{238, 238, 238, 255},
+		{250, 250, 250, 231},
+	} {
+		if got := nearest(test.r, test.g, test.b); got != test.want {
+			t.Errorf("nearest(%d, %d, %d) = %d, want %d", test.r, test.g, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package video
+
+import (
+	"time"
+)
+
+// Double buffering
+// With double buffering on, the screen shows Front instead of VRAM. A flip
+// copies VRAM to Front at the next vertical blank, so a frame is only seen
+// once the program has finished drawing it.
+var DoubleBuffer bool = false
+var Front [153600]byte
+var flipPending bool = false
+// Vertical blanks since the machine started
+var FrameCount uint64 = 0
+// Interrupt raised on VBlankCore at every vertical blank, 0 for none
+var VBlankMessage uint32 = 0
+var VBlankCore uint32 = 0
+// Tells the window a frame is ready, it never blocks the CPU
+var frameReady = make(chan bool, 1)
+
+// Called by the CPU at every vertical blank
+func VBlank() {
+	scrollLock.Lock()
+	FrameCount++
+	if flipPending == true {
+		copy(Front[:], MemoryVideo[:])
+		flipPending = false
+	}
+	scrollLock.Unlock()
+	select {
+	case frameReady <- true:
+	default:
+	}
+}
+
+func Flip() {
+	scrollLock.Lock()
+	defer scrollLock.Unlock()
+	flipPending = DoubleBuffer
+}
+
+// Turning double buffering on starts the front buffer with what is on screen
+func SetDoubleBuffer(on bool) {
+	scrollLock.Lock()
+	defer scrollLock.Unlock()
+	if on == true && DoubleBuffer == false {
+		copy(Front[:], MemoryVideo[:])
+	}
+	DoubleBuffer = on
+	flipPending = false
+}
+
+// Waits until a frame is ready or the timeout passes, for the window
+func WaitFrame(timeout time.Duration) {
+	select {
+	case <-frameReady:
+	case <-time.After(timeout):
+	}
+}
+
+// What the screen shows, called with scrollLock held
+func display() []byte {
+	if DoubleBuffer == true {
+		return Front[:mode().Size]
+	}
+	return VRAM()
+}
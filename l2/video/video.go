@@ -1,6 +1,8 @@
 package video
 
 import (
+	"bytes"
+	"image"
 	"image/color"
 	"luna_l2/font"
 	"cmp"
@@ -8,7 +10,8 @@ import (
 
 var CursorX int = 0
 var CursorY int = 0
-var MemoryVideo [64000]byte
+// Large enough for every video mode, see mode.go
+var MemoryVideo [153600]byte
 var Palette [256]color.NRGBA
 
 func Clamp[T cmp.Ordered](x T, min T, max T) T {
@@ -40,41 +43,114 @@ func PushChar(x, y int, ch rune, fg byte, bg byte) {
 			} else {
 				color = bg
 			}
-			px := (y+row)*320 + (x+col)
-			MemoryVideo[Clamp(int(px), 0, 63999)] = color
+			SetPixel(x+col, y+row, color)
 		}
 
     }
 }
 
 func PrintChar(ch rune, fg byte, bg byte) {
-	if ch == 0x0a {
-		CursorY++
+	if ansi(ch, fg, bg) == true {
+		return
+	}
+	fg, bg = Colors(fg, bg)
+	put(ch, fg, bg)
+}
+
+// Prints a character in colours that already have the attributes applied
+func put(ch rune, fg byte, bg byte) {
+	switch ch {
+	case 0x0a:
 		CursorX = 0
+		newline()
 		return
-	} else if ch == 0x0d {
+	case 0x0d:
 		CursorX = 0
 		return
-	}	
-
-	x := CursorX * 8
-	y := CursorY * 8	
+	case 0x09:
+		// Tab stops every 8 columns, the skipped cells are cleared
+		for {
+			put(' ', fg, bg)
+			if CursorX % 8 == 0 {
+				break
+			}
+		}
+		return
+	case 0x08:
+		// Backspace erases the cell before the cursor, going back to the end
+		// of the previous row from the first column
+		if CursorX > 0 {
+			CursorX--
+		} else if CursorY > 0 {
+			CursorY--
+			CursorX = mode().Columns - 1
+		} else {
+			return
+		}
+		drawCell(CursorX, CursorY, ' ', fg, bg)
+		return
+	case 0x0c:
+		// Form feed clears the screen in the background colour
+		Clear(bg)
+		return
+	}
 
-	PushChar(x, y, ch, fg, bg)
+	drawCell(CursorX, CursorY, ch, fg, bg)
 
 	CursorX++
-	if CursorX >= 320/8 {
-		CursorY++
+	if CursorX >= mode().Columns {
 		CursorX = 0
+		newline()
+	}
+}
+
+// Reads back the character drawn in a console cell of a screen in a mode. In
+// the graphics modes it is matched against the font in any colours, returns
+// false if no glyph matches. Empty cells read as spaces. The character is the
+// glyph number, font.Char gives the Unicode character it draws.
+func CharAt(screen []byte, id int, column int, row int) (rune, bool) {
+	m := Modes[id]
+	if column < 0 || column >= m.Columns || row < 0 || row >= m.Rows {
+		return 0, false
 	}
-	if CursorY >= 200/8 {
-		for i := 0; i <= 63999; i++ {
-			MemoryVideo[i] = byte(00)
-		} 
-		CursorY = 0
+	if m.Depth == 0 {
+		ch := screen[(row * m.Columns + column) * 2]
+		if ch == 0 {
+			ch = ' '
+		}
+		return rune(ch), true
 	}
+	for ch := 0x20; ch < len(font.Font); ch++ {
+		glyph := font.Font[ch]
+		fg, bg := -1, -1
+		match := true
+		for y := 0; y < 8 && match == true; y++ {
+			for x := 0; x < 8; x++ {
+				pixel := int(Pixel(screen, id, column*8 + x, row*m.CellHeight + y))
+				if glyph[y] & byte(1 << x) != 0 {
+					if fg == -1 {
+						fg = pixel
+					}
+					match = pixel == fg
+				} else {
+					if bg == -1 {
+						bg = pixel
+					}
+					match = pixel == bg
+				}
+				if match == false {
+					break
+				}
+			}
+		}
+		if match == true && (fg != bg || fg == -1) {
+			return rune(ch), true
+		}
+	}
+	return 0, false
 }
 
+// Sets the default palette of the current mode
 func InitializePalette() {
 	for i := 0; i < 256; i++ {
 		r := (i >> 5) & 0x07
@@ -87,4 +163,35 @@ func InitializePalette() {
 
         Palette[i] = color.NRGBA{R, G, B, 255}
     }
+	// The other modes start with colours they can use in the first entries
+	switch mode().Depth {
+	case 0, 4:
+		copy(Palette[:], ansiPalette[:])
+	case 1:
+		Palette[0] = color.NRGBA{0, 0, 0, 255}
+		Palette[1] = color.NRGBA{255, 255, 255, 255}
+	}
+}
+
+// Whether two images show the same pixels in the same colours
+func SameImage(a *image.Paletted, b *image.Paletted) bool {
+	if a.Rect != b.Rect || len(a.Palette) != len(b.Palette) || bytes.Equal(a.Pix, b.Pix) == false {
+		return false
+	}
+	for i := range a.Palette {
+		if a.Palette[i] != b.Palette[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// The screen as an image in the current palette, with the sprites and tiles
+func Image() *image.Paletted {
+	scrollLock.Lock()
+	screen, id := bytes.Clone(display()), ActiveMode
+	scrollLock.Unlock()
+	img := Render(screen, id)
+	Composite(img)
+	return img
 }
@@ -0,0 +1,234 @@
+package linker
+
+import (
+	"fmt"
+	"os"
+	"bytes"
+)
+
+type binding struct {
+	Name string
+	Location []byte
+	Address int
+}
+
+// A reference to a label that is filled in once every label has an address
+type fixup struct {
+	Name string
+	Position int
+	Width int
+	Relative bool
+}
+
+var DataBuffer []byte
+var TextBuffer []byte
+var ExtendedDataBuffer []byte
+var section string = "text"
+
+var bindings = []binding {}
+var PIE bool = false
+
+var errors = []string {
+	"no object files specified",
+	"file cannot be open()ed, errno=2",
+	"multiple definitions of",
+	"Undefined symbol for architecture luna-l2:",	
+	"relative reference out of range:",
+	"absolute reference cannot be used when making a PIE, use lea or a relative branch for",
+}
+// Unwinds Link after an error has been printed
+type failure struct{}
+
+func error(errno int, args string) {
+	fmt.Fprintln(os.Stderr, "l2ld: " + errors[errno] + " " + args)
+	panic(failure{})
+}
+
+// Reports an error outside of linking, such as a missing object file
+func Error(errno int, args string) {
+	fmt.Fprintln(os.Stderr, "l2ld: " + errors[errno] + " " + args)
+}
+
+func write(content byte) {
+	switch section {
+	case "data":
+		DataBuffer = append(DataBuffer, content)
+	case "text":
+		TextBuffer = append(TextBuffer, content)
+	case "edata":
+		ExtendedDataBuffer = append(ExtendedDataBuffer, content)
+	}	
+}
+
+func checkBinding(name string) ([]byte, bool) {
+	for i := range bindings {
+		if bindings[i].Name == name {
+			return bindings[i].Location, true
+		}
+	}
+	return nil, false
+}
+
+func separate(data []byte) {	
+	for i := 0; i < len(data); i++ {
+		if i + 2 < len(data) {
+			bytes := uint32(data[i]) << 16 | uint32(data[i + 1]) << 8 | uint32(data[i + 2])
+			switch bytes {
+			case 0xC2807D:
+				section = "data"
+				i += 2
+			case 0xC2807E:
+				section = "text"
+				i += 2
+			case 0xC2807F:
+				section = "edata"
+				i += 2	
+			default:
+				write(data[i])
+			}
+		} else {
+			write(data[i])
+		}
+	}	
+}
+
+// Reads a marker such as "LR_name\0" at data[i:], returning the name and the
+// index of the terminating NUL
+func marker(data []byte, i int, prefix string) (string, int, bool) {
+	if bytes.HasPrefix(data[i:], []byte(prefix)) == false {
+		return "", i, false
+	}
+	j := i + len(prefix)
+	for j < len(data) && data[j] != 0x00 {
+		j++
+	}
+	return string(data[i + len(prefix):j]), j, true
+}
+
+// Lays out the sections after the two byte start address, replacing label
+// definitions with nothing and references with their resolved values
+func link() []byte {
+	var sections = [][]byte {DataBuffer, TextBuffer, ExtendedDataBuffer}
+
+	// Absolute references take the width of the label they point to
+	widths := map[string]int {}
+	for _, data := range sections {
+		for i := 0; i < len(data); i++ {
+			if name, j, ok := marker(data, i, "LD16_"); ok == true {
+				widths[name] = 2
+				i = j
+			} else if name, j, ok := marker(data, i, "LD32_"); ok == true {
+				widths[name] = 4
+				i = j
+			}
+		}
+	}
+
+	image := []byte{0x00, 0x00}
+	fixups := []fixup {}
+	for _, data := range sections {
+		for i := 0; i < len(data); i++ {
+			if name, j, ok := marker(data, i, "LD16_"); ok == true {
+				_, found := checkBinding(name)
+				if found == true {
+					error(2, "`" + name + "'")
+				}
+				location := len(image)
+				bindings = append(bindings, binding{Name: name, Location: []byte{byte(location >> 8), byte(location & 0xFF)}, Address: location})
+				i = j
+			} else if name, j, ok := marker(data, i, "LD32_"); ok == true {
+				_, found := checkBinding(name)
+				if found == true {
+					error(2, "`" + name + "'")
+				}
+				location := len(image)
+				bindings = append(bindings, binding{Name: name, Location: []byte{byte(location >> 24), byte(location >> 16), byte(location >> 8), byte(location & 0xFF)}, Address: location})
+				i = j
+			} else if name, j, ok := marker(data, i, "LR_"); ok == true {
+				width, found := widths[name]
+				if found == false {
+					error(3, "\n  \"" + name + "\", referenced from\n    <initial-undefines>")
+				}
+				if PIE == true {
+					error(5, "`" + name + "'")
+				}
+				fixups = append(fixups, fixup{Name: name, Position: len(image), Width: width})
+				image = append(image, make([]byte, width)...)
+				i = j
+			} else if name, j, ok := marker(data, i, "LP8_"); ok == true {
+				fixups = append(fixups, fixup{Name: name, Position: len(image), Width: 1, Relative: true})
+				image = append(image, 0x00)
+				i = j
+			} else if name, j, ok := marker(data, i, "LP16_"); ok == true {
+				fixups = append(fixups, fixup{Name: name, Position: len(image), Width: 2, Relative: true})
+				image = append(image, 0x00, 0x00)
+				i = j
+			} else if name, j, ok := marker(data, i, "LP32_"); ok == true {
+				fixups = append(fixups, fixup{Name: name, Position: len(image), Width: 4, Relative: true})
+				image = append(image, 0x00, 0x00, 0x00, 0x00)
+				i = j
+			} else {
+				image = append(image, data[i])
+			}
+		}
+	}
+
+	for _, f := range fixups {
+		var target *binding
+		for i := range bindings {
+			if bindings[i].Name == f.Name {
+				target = &bindings[i]
+				break
+			}
+		}
+		if target == nil {
+			error(3, "\n  \"" + f.Name + "\", referenced from\n    <initial-undefines>")
+		}
+
+		// Displacements are relative to the end of the field, which is where
+		// the instruction using them ends
+		value := target.Address
+		if f.Relative == true {
+			value = target.Address - (f.Position + f.Width)
+			limit := 1 << (f.Width * 8 - 1)
+			if f.Width < 4 && (value < -limit || value >= limit) {
+				error(4, "`" + f.Name + "'")
+			}
+		}
+		for k := 0; k < f.Width; k++ {
+			image[f.Position + k] = byte(value >> ((f.Width - 1 - k) * 8))
+		}
+	}
+	return image
+}
+
+// Links objects made by las into a bootable image whose first two bytes hold
+// the address of _start. Returns false after printing the error.
+func Link(objects [][]byte, pie bool) (image []byte, ok bool) {
+	DataBuffer = []byte {}
+	TextBuffer = []byte {}
+	ExtendedDataBuffer = []byte {}
+	section = "text"
+	bindings = []binding {}
+	PIE = pie
+	defer func() {
+		if r := recover(); r != nil {
+			if _, linking := r.(failure); linking == false {
+				panic(r)
+			}
+			image = nil
+			ok = false
+		}
+	}()
+
+	for _, data := range objects {
+		separate(data)
+	}
+	image = link()
+	startloc, found := checkBinding("_start")
+	if found == false {
+		error(3, "\n  \"_start\", referenced from\n    <initial-undefines>")	
+	}
+	copy(image[0:2], startloc)
+	return image, true
+}
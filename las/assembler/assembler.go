@@ -0,0 +1,1248 @@
+package assembler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var section string = "text"
+var DataBuffer []byte
+var TextBuffer []byte
+var ExtendedDataBuffer []byte
+// File being assembled, used in diagnostics
+var current_filename string = ""
+var Bits32 bool = false
+var ForcedSize int64 = 0
+
+// Branch relaxation
+// Every file is laid out twice before it is assembled. The layout passes emit
+// every relative branch in its long form: the first finds the width of each
+// label, so that references to it are sized as l2ld sizes them, and the second
+// records where labels and branches end up. The final pass then uses the short
+// form for branches whose target is a label in the same file and section that
+// is close enough.
+type location struct {
+	Section string
+	Offset int
+	// References so far to labels of other files, whose width is not known
+	Unknown int
+}
+
+type branch struct {
+	ID int
+	Target string
+	End location
+}
+
+var Layout bool = false
+var Offsets = map[string]int {}
+var Labels = map[string]location {}
+var Widths = map[string]int {}
+var Unknown = map[string]int {}
+var Branches = []branch {}
+var BranchCount int = 0
+var ShortBranches = map[int]bool {}
+
+// Size of a chunk once linked, markers are replaced or removed by l2ld
+func size(b []byte) int {
+	switch {
+	case bytes.HasPrefix(b, []byte("LD16_")), bytes.HasPrefix(b, []byte("LD32_")):
+		return 0
+	case bytes.HasPrefix(b, []byte("LR_")):
+		// l2ld gives absolute references the width of their label
+		if width, ok := Widths[string(bytes.TrimSuffix(b[3:], []byte{0x00}))]; ok == true {
+			return width
+		}
+		if Bits32 == false {
+			return 2
+		}
+		return 4
+	case bytes.HasPrefix(b, []byte("LP8_")):
+		return 1
+	case bytes.HasPrefix(b, []byte("LP16_")):
+		return 2
+	case bytes.HasPrefix(b, []byte("LP32_")):
+		return 4
+	}
+	return len(b)
+}
+
+func write(b []byte) {
+	Offsets[section] += size(b)
+	if bytes.HasPrefix(b, []byte("LR_")) == true {
+		if _, ok := Widths[string(bytes.TrimSuffix(b[3:], []byte{0x00}))]; ok == false {
+			Unknown[section]++
+		}
+	}
+	switch section {
+	case "data":
+		DataBuffer = append(DataBuffer, b...)
+	case "text":
+		TextBuffer = append(TextBuffer, b...)
+	case "edata":
+		ExtendedDataBuffer = append(ExtendedDataBuffer, b...)
+	}
+}
+
+func isRegister(word string) byte {
+	switch word {
+	case "r0":
+		return 0x00
+	case "r1":
+		return 0x01
+	case "r2":
+		return 0x02
+	case "r3":
+		return 0x03
+	case "r4":
+		return 0x04
+	case "r5":
+		return 0x05
+	case "r6":
+		return 0x06
+	case "r7":
+		return 0x07
+	case "r8":
+		return 0x08
+	case "r9":
+		return 0x09
+	case "r10":
+		return 0x0a
+	case "r11":
+		return 0x0b
+	case "r12":
+		return 0x0c
+	case "t1":
+		return 0x0d
+	case "t2":
+		return 0x0e
+	case "t3":
+		return 0x0f
+	case "t4":
+		return 0x10
+	case "t5":
+		return 0x11
+	case "t6":
+		return 0x12
+	case "t7":
+		return 0x13
+	case "t8":
+		return 0x14
+	case "t9":
+		return 0x15
+	case "t10":
+		return 0x16
+	case "t11":
+		return 0x17
+	case "t12":
+		return 0x18
+	case "sp":
+		return 0x19
+	case "pc":
+		return 0x1a
+	case "re1":
+		return 0x1b
+	case "re2":
+		return 0x1c
+	case "re3":
+		return 0x1d
+	default:
+		return 0xff
+	}
+}
+
+var errors = []string{
+	"no input files",
+	"no such file or directory",
+	"invalid register name",
+	"invalid operand to instruction",
+	"invalid instruction mnemonic",
+	"immediate value too large",
+	"missing terminating '\"' character",
+	"expected string",
+	"invalid architecture",
+	"invalid argument to 'bits', must be 16 or 32",
+	"putting more than one character to a register may have undesirable results",
+	"expected number",
+	"unknown pragma directive",
+}
+var Errors int
+var Warnings int
+
+func error(errno int, args string) {
+	if Layout == true {
+		Errors++
+		return
+	}
+	label := ""
+
+	if current_filename != "" {
+		label = current_filename
+	} else {
+		label = "lcc"
+	}
+
+	fmt.Fprintln(os.Stderr, "\033[1;39m" + label + ": \033[1;31merror: \033[1;39m" + errors[errno] + " " + args + "\033[0m")
+	Errors++
+}
+
+// Reports an error outside of assembly, such as a missing input file
+func Error(errno int, args string) {
+	error(errno, args)
+}
+
+func warning(errno int, args string) {
+	if Layout == true {
+		Warnings++
+		return
+	}
+	label := ""
+
+	if current_filename != "" {
+		label = current_filename
+	} else {
+		label = "lcc"
+	}
+
+	fmt.Println("\033[1;39m" + label + ": \033[1;33mwarning: \033[1;39m" + errors[errno] + " " + args + "\033[0m")
+	Warnings++
+}
+
+func parse(text string) []byte {
+	// Check for number
+	if _, err := strconv.ParseInt(text, 0, 64); err == nil {
+		num, _ := strconv.ParseInt(text, 0, 64)
+		if Bits32 == false {
+			H := byte(num >> 8)
+			L := byte(num & 0xFF)
+			return []byte{H, L}
+		} else {
+			HH := byte(num >> 24)
+			HL := byte(num >> 16)
+			LH := byte(num >> 8)
+			LL := byte(num & 0xFF)
+			return []byte{HH, HL, LH, LL}	
+		}
+	}
+	if isRegister(text) != 0xff {	
+		return []byte{byte(isRegister(text))}
+	}
+	if string(text[0]) == "\"" {
+		if string(text[len(text)-1]) != "\"" {
+			error(6, "")
+		}
+
+		text = strings.Trim(text, "\"")
+
+		text = strings.ReplaceAll(text, "\\0", "\000")
+		text = strings.ReplaceAll(text, "\\n", "\n")
+		text = strings.ReplaceAll(text, "\\r", "\r")
+		if Bits32 == false {
+			if len(text) > 2 {
+				error(5, "'" + text + "'")
+			} else if len(text) == 1 {
+				text = string(byte(00)) + text
+			} else {
+				warning(10, "")
+			}
+		} else {
+			if len(text) > 4 {
+				error(5, "'" + text + "'")	
+			} else if len(text) == 1 {
+				text = string(byte(00)) + string(byte(00)) + string(byte(00)) + text
+			} else if len(text) == 2 {
+				text = string(byte(00)) + string(byte(00)) + text
+				warning(10, "")
+			} else if len(text) == 3 {
+				text = string(byte(00)) + text
+				warning(10, "")
+			} else {	
+				warning(10, "")
+			}
+		}	
+		return []byte(text)
+	}
+	return append([]byte("LR_"+text), 0x00)
+}
+
+// Emits a PC-relative reference to a label
+// <opcode> <mode (03 short or 04 long)> <register> <displacement>
+func relative(opcode byte, register []byte, target string) {
+	BranchCount++
+	var mode byte = 0x04
+	marker := "LP16_"
+	if Bits32 == true {
+		marker = "LP32_"
+	}
+	if ShortBranches[BranchCount] == true {
+		mode = 0x03
+		marker = "LP8_"
+	}
+	write([]byte{opcode, mode})
+	write(register)
+	write(append([]byte(marker + target), 0x00))
+	if Layout == true {
+		Branches = append(Branches, branch{ID: BranchCount, Target: target, End: location{Section: section, Offset: Offsets[section], Unknown: Unknown[section]}})
+	}
+}
+
+// Picks the branches that can use an 8-bit displacement after the layout
+// passes. Each reference to a label of another file between a branch and its
+// target was laid out at the current width and may end up 2 bytes longer or
+// shorter, so it narrows the range.
+func relax() {
+	ShortBranches = map[int]bool {}
+	for _, b := range Branches {
+		label, ok := Labels[b.Target]
+		if ok == false || label.Section != b.End.Section {
+			continue
+		}
+		disp := label.Offset - b.End.Offset
+		slack := 2 * (label.Unknown - b.End.Unknown)
+		if slack < 0 {
+			slack = -slack
+		}
+		if disp - slack >= -128 && disp + slack <= 127 {
+			ShortBranches[b.ID] = true
+		}
+	}
+}
+
+func isComment(text string) bool {
+	return text == "#" || text == "//" || text == ";"
+}
+
+func isNumber(text string) bool {
+	_, err := strconv.ParseInt(text, 0, 64)
+	return err == nil
+}
+
+func formatString(text string) string {
+	// \033 goes before \0, which would otherwise take its first two characters
+	var replace = [][2]string {
+		{"\\033", "\033"},
+		{"\\0", "\000"},
+		{"\\n", "\n"},
+		{"\\r", "\r"},
+	}
+	for _, pair := range replace {
+		text = strings.ReplaceAll(text, pair[0], pair[1])
+	}
+	return text
+}
+
+func Lex(text string) []string {
+	var tokens = []string {}
+	var buf = []rune {}
+
+	for _, r := range text {
+		switch {
+		case r == '\n':	
+			if len(buf) > 0 {
+				tokens = append(tokens, string(buf))
+				buf = buf[:0]
+			}
+			tokens = append(tokens, "\n")
+		case unicode.IsSpace(r):
+			if len(buf) > 0 {
+				tokens = append(tokens, string(buf))
+				buf = buf[:0]
+			}
+		default:
+			buf = append(buf, r)
+		}
+	}
+
+	if len(buf) > 0 {
+		tokens = append(tokens, string(buf))
+	}
+
+	return tokens
+}
+
+func assemble(text string) {
+	words := Lex(text)
+
+	for i := 0; i < len(words); i++ {
+		words[i] = strings.TrimSuffix(words[i], ",")
+	}
+
+	for i := 0; i < len(words); i++ {
+		switch words[i] {
+		case "#define":
+			alias := words[i + 1]
+			actual := words[i + 2]
+			words = append(words[:i], words[i + 3:]...)
+			for j := 0; j < len(words); j++ {
+				if words[j] == alias {
+					words[j] = actual
+				}
+			}
+		case "#pragma":
+			switch words[i + 1] {
+			case "size":
+				size, err := strconv.ParseInt(words[i + 2], 0, 64)
+				if err != nil {
+					error(11, "")
+					break
+				}
+				ForcedSize = size
+				i++
+			default:
+				warning(12, "'" + words[i + 1] + "'")	
+			}
+			i++
+		}
+	}
+
+	for i := 0; i < len(words); i++ {
+		if strings.HasSuffix(words[i], ":") {
+			end := len(words)
+			for j := i + 1; j < len(words); j++ {
+				if strings.HasSuffix(words[j], ":") {
+					end = j
+					break
+				}
+			}
+
+			words[i] = strings.TrimSuffix(words[i], ":")
+			if Layout == true {
+				Labels[words[i]] = location{Section: section, Offset: Offsets[section], Unknown: Unknown[section]}
+			}
+			if Bits32 == false || words[i] == "_start" {
+				Widths[words[i]] = 2
+				write(append([]byte("LD16_" + words[i]), 0x00))
+			} else {
+				Widths[words[i]] = 4
+				write(append([]byte("LD32_" + words[i]), 0x00))
+			}
+
+			tocompile := words[i+1 : end]
+			if len(tocompile) > 0 {
+				assemble(strings.Join(tocompile, " "))
+			}
+
+			i = end - 1
+			continue
+		}
+
+		words[i] = strings.ToLower(words[i])
+		switch words[i] {
+		case ".data":
+			section = "data"
+		case ".text":
+			section = "text"
+		case ".edata":
+			section = "edata"
+		case "#", "//", ";":
+			for j := i + 1; j < len(words); j++ {
+				if words[j] == "\n" {
+					i = j
+					break
+				}
+			}
+		case "\n":
+			continue
+		case "mov":
+			write([]byte{0x01})
+
+			var mode byte
+			if isRegister(words[i+2]) == 0xff {
+				mode = 0x01
+			} else {
+				mode = 0x02
+			}
+			write([]byte{mode})
+
+			dst := isRegister(words[i+1])
+			if dst == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{dst})
+
+			if mode == 0x02 {
+				src := isRegister(words[i+2])
+				write([]byte{src})
+			} else {
+				value := parse(words[i+2])
+				write(value)
+			}
+			i = i + 2
+		case "hlt":
+			write([]byte{0x02})
+		case "jmp":
+			if isRegister(words[i+1]) == 0xff && isNumber(words[i+1]) == false {
+				relative(0x03, nil, words[i+1])
+				i = i + 1
+				continue
+			}
+			write([]byte{0x03})
+
+			if isRegister(words[i+1]) == 0xff {
+				write([]byte{0x01})
+			} else {
+				write([]byte{0x02})
+			}
+
+			value := parse(words[i+1])
+			write(value)
+			i = i + 1
+		case "int":
+			write([]byte{0x04})
+			value := parse(words[i+1])	
+			if Bits32 == false {
+				if len(value) > 2 {
+					error(3, "'" + string(value) + "'")
+				}
+			} else {
+				if len(value) > 4 {
+					error(3, "'" + string(value) + "'")
+				}
+			}
+			write(value)
+			i = i + 1
+		case "jnz":
+			if isRegister(words[i+2]) == 0xff && isNumber(words[i+2]) == false {
+				register := isRegister(words[i+1])
+				if register == 0xff {
+					error(2, "'"+words[i+1]+"'")
+				}
+				relative(0x05, []byte{register}, words[i+2])
+				i = i + 2
+				continue
+			}
+			write([]byte{0x05})
+
+			if isRegister(words[i+2]) == 0xff {
+				write([]byte{0x01})
+			} else {
+				write([]byte{0x02})
+			}
+
+			register := isRegister(words[i+1])
+			if register == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{register})
+
+			value := parse(words[i+2])
+			write(value)
+			i = i + 2
+		case "nop":
+			write([]byte{0x06})
+		case "cmp":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x07})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "jz":
+			if isRegister(words[i+2]) == 0xff && isNumber(words[i+2]) == false {
+				register := isRegister(words[i+1])
+				if register == 0xff {
+					error(2, "'"+words[i+1]+"'")
+				}
+				relative(0x08, []byte{register}, words[i+2])
+				i = i + 2
+				continue
+			}
+			write([]byte{0x08})
+
+			if isRegister(words[i+2]) == 0xff {
+				write([]byte{0x01})
+			} else {
+				write([]byte{0x02})
+			}
+
+			register := isRegister(words[i+1])
+			if register == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{register})
+
+			value := parse(words[i+2])
+			write(value)
+			i = i + 2
+		case "inc":
+			write([]byte{0x09})
+			reg := isRegister(words[i+1])
+			if reg == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{reg})
+			i = i + 1
+		case "dec":
+			write([]byte{0x0a})
+			reg := isRegister(words[i+1])
+			if reg == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{reg})
+			i = i + 1
+		case "push":
+			write([]byte{0x0b})
+			if isRegister(words[i+1]) == 0xff {
+				write([]byte{0x01})
+			} else {
+				write([]byte{0x02})
+			}
+			write(parse(words[i+1]))
+			i = i + 1
+		case "pop":
+			write([]byte{0x0c})
+			reg := isRegister(words[i+1])
+			if reg == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{reg})
+			i = i + 1
+		case "add":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x0d})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "sub":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x0e})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "mul":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x0f})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "div":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x10})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "igt":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x11})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "ilt":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x12})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "and":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x13})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "or":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x14})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "nor":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x15})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "not":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{0x16})
+			write([]byte{check})
+			write([]byte{one})
+			i = i + 2
+		case "xor":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{0x17})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "lod":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{0x18})
+			write([]byte{check})
+			write([]byte{one})
+			i = i + 2
+		case "str":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{0x19})
+			write([]byte{check})
+			write([]byte{one})
+			i = i + 2
+		case "lodf":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{0x1a})
+			write([]byte{check})
+			write([]byte{one})
+			i = i + 2
+		case "ldb", "ldw", "ldd", "stb", "stw", "std":
+			var opcodes = map[string]byte {
+				"ldb": 0x2b,
+				"ldw": 0x2c,
+				"ldd": 0x2d,
+				"stb": 0x2e,
+				"stw": 0x2f,
+				"std": 0x30,
+			}
+			reg := isRegister(words[i+1])
+			base := isRegister(words[i+2])
+			if reg == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if base == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{opcodes[words[i]], reg, base})
+			// The offset is optional
+			if i + 3 < len(words) && words[i+3] != "\n" && isComment(words[i+3]) == false {
+				if isRegister(words[i+3]) != 0xff {
+					error(3, "'"+words[i+3]+"'")
+				}
+				write(parse(words[i+3]))
+				i = i + 3
+			} else {
+				write(parse("0"))
+				i = i + 2
+			}
+		case "cas", "xadd":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			if words[i] == "cas" {
+				write([]byte{0x31})
+			} else {
+				write([]byte{0x32})
+			}
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "cid", "ivec":
+			reg := isRegister(words[i+1])
+			if reg == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if words[i] == "cid" {
+				write([]byte{0x33})
+			} else {
+				write([]byte{0x35})
+			}
+			write([]byte{reg})
+			i = i + 1
+		case "ipi":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{0x34})
+			write([]byte{check})
+			write([]byte{one})
+			i = i + 2
+		case "set":
+			mode := words[i + 1]
+
+			switch mode {
+			case "16":
+				write([]byte{0x1b, 0x00})
+			case "32":
+				write([]byte{0x1b, 0x01})
+			}
+			i++
+		case "shl", "shr", "sar", "rol", "ror":
+			var opcodes = map[string]byte {
+				"shl": 0x1c,
+				"shr": 0x1d,
+				"sar": 0x1e,
+				"rol": 0x1f,
+				"ror": 0x20,
+			}
+			dst := isRegister(words[i+1])
+			src := isRegister(words[i+2])
+			if dst == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if src == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			write([]byte{opcodes[words[i]]})
+			if isRegister(words[i+3]) == 0xff {
+				count, err := strconv.ParseInt(words[i+3], 0, 64)
+				if err != nil {
+					error(11, "'"+words[i+3]+"'")
+				} else if count < 0 || count > 0xff {
+					error(5, "'"+words[i+3]+"'")
+				}
+				write([]byte{0x01, dst, src, byte(count)})
+			} else {
+				write([]byte{0x02, dst, src, isRegister(words[i+3])})
+			}
+			i = i + 3
+		case "sgt", "slt", "sdiv", "mod", "smod":
+			var opcodes = map[string]byte {
+				"sgt": 0x21,
+				"slt": 0x22,
+				"sdiv": 0x23,
+				"mod": 0x24,
+				"smod": 0x25,
+			}
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			two := isRegister(words[i+3])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if two == 0xff {
+				error(2, "'"+words[i+3]+"'")
+			}
+			write([]byte{opcodes[words[i]]})
+			write([]byte{check})
+			write([]byte{one})
+			write([]byte{two})
+			i = i + 3
+		case "sxb", "sxw":
+			check := isRegister(words[i+1])
+			one := isRegister(words[i+2])
+			if check == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			if one == 0xff {
+				error(2, "'"+words[i+2]+"'")
+			}
+			if words[i] == "sxb" {
+				write([]byte{0x26})
+			} else {
+				write([]byte{0x27})
+			}
+			write([]byte{check})
+			write([]byte{one})
+			i = i + 2
+		case "lea":
+			reg := isRegister(words[i+1])
+			if reg == 0xff {
+				error(2, "'"+words[i+1]+"'")
+			}
+			write([]byte{0x28, reg})
+			if Bits32 == false {
+				write(append([]byte("LP16_" + words[i+2]), 0x00))
+			} else {
+				write(append([]byte("LP32_" + words[i+2]), 0x00))
+			}
+			i = i + 2
+		case "call":
+			if isRegister(words[i+1]) == 0xff && isNumber(words[i+1]) == false {
+				relative(0x29, nil, words[i+1])
+				i = i + 1
+				continue
+			}
+			write([]byte{0x29})
+
+			if isRegister(words[i+1]) == 0xff {
+				write([]byte{0x01})
+			} else {
+				write([]byte{0x02})
+			}
+
+			value := parse(words[i+1])
+			write(value)
+			i = i + 1
+		case "ret":
+			write([]byte{0x2a})
+		case ".ascii":	
+			var value string	
+			var tokens = []string {}
+			
+			if string(words[i+1][0]) != "\"" {
+				error(7, "'" + words[i+1] + "'")
+			}
+			if strings.HasSuffix(words[i + 1], "\"") {
+				value = strings.Trim(words[i + 1], "\"")
+				value = formatString(value)
+				write([]byte(value))
+				i = i + 1
+				continue
+			}
+			
+			ending := 0
+			for j := i + 1; j < len(words); j++ {
+				tokens = append(tokens, words[j])
+				if strings.HasSuffix(words[j], "\"") {
+					ending = j
+					break
+				}
+			}
+			if ending == 0 {
+				error(6, "'" + words[i + 1] + "'")
+			}
+			
+			tokens[0] = strings.TrimPrefix(tokens[0], "\"")
+			tokens[len(tokens) - 1] = strings.TrimSuffix(tokens[len(tokens) - 1], "\"")
+			value = strings.Join(tokens, " ")
+			value = formatString(value)
+			write([]byte(value))
+			i = ending
+		case ".asciz":	
+			var value string	
+			var tokens = []string {}
+			
+			if string(words[i+1][0]) != "\"" {
+				error(7, "'" + words[i+1] + "'")
+			}
+			if strings.HasSuffix(words[i + 1], "\"") {
+				value = strings.Trim(words[i + 1], "\"")
+				value = formatString(value)
+				value = value + string("\000")
+				write([]byte(value))
+				i = i + 1
+				continue
+			}
+			
+			ending := 0
+			for j := i + 1; j < len(words); j++ {
+				tokens = append(tokens, words[j])
+				if strings.HasSuffix(words[j], "\"") {
+					ending = j
+					break
+				}
+			}
+			if ending == 0 {
+				error(6, "'" + words[i + 1] + "'")
+			}
+			
+			tokens[0] = strings.TrimPrefix(tokens[0], "\"")
+			tokens[len(tokens) - 1] = strings.TrimSuffix(tokens[len(tokens) - 1], "\"")
+			value = strings.Join(tokens, " ")
+			value = formatString(value)
+			value = value + string("\000")
+			write([]byte(value))
+			i = ending
+		case ".byte", ".word", ".dword":
+			var size = map[string]int {
+				".byte": 1,
+				".word": 2,
+				".dword": 4,
+			}[words[i]]
+			j := i + 1
+			for ; j < len(words) && words[j] != "\n" && isComment(words[j]) == false; j++ {
+				num, err := strconv.ParseInt(words[j], 0, 64)
+				if err != nil {
+					error(11, "'" + words[j] + "'")
+					continue
+				}
+				if num >= int64(1) << (size * 8) || num < -(int64(1) << (size * 8 - 1)) {
+					error(5, "'" + words[j] + "'")
+				}
+				for k := size - 1; k >= 0; k-- {
+					write([]byte{byte(num >> (k * 8))})
+				}
+			}
+			i = j - 1
+		case "bits":
+			switch words[i + 1] {
+			case "16":
+				Bits32 = false	
+			case "32":
+				Bits32 = true
+			default:
+				error(9, "")
+			}
+			i++
+		case ".embed":
+			file := words[i + 1]
+			data, err := os.ReadFile(file)
+			if err != nil {
+				error(1, "'" + file + "'")
+				continue
+			}
+			write(data)
+			i++
+		default:
+			error(4, "'"+words[i]+"'")
+		}
+	}
+}
+
+// Clears the state kept between files, including the bits mode
+func Reset() {
+	DataBuffer = []byte {}
+	TextBuffer = []byte {}
+	ExtendedDataBuffer = []byte {}
+	section = "text"
+	Bits32 = false
+	ForcedSize = 0
+	Offsets = map[string]int {}
+	Labels = map[string]location {}
+	Widths = map[string]int {}
+	Unknown = map[string]int {}
+	Branches = []branch {}
+	BranchCount = 0
+	ShortBranches = map[int]bool {}
+	Errors = 0
+	Warnings = 0
+}
+
+// Assembles a file without output or diagnostics to find where everything
+// ends up, starting from a bits mode
+func layout(data string, bits bool) {
+	Layout = true
+	DataBuffer = []byte {}
+	TextBuffer = []byte {}
+	ExtendedDataBuffer = []byte {}
+	section = "text"
+	Bits32 = bits
+	Offsets = map[string]int {}
+	Unknown = map[string]int {}
+	Labels = map[string]location {}
+	Branches = []branch {}
+	BranchCount = 0
+	assemble(data)
+	Layout = false
+	Errors = 0
+	Warnings = 0
+}
+
+// Assembles one file into an object for l2ld, returns false if there were
+// errors. Diagnostics are printed as they are found. The bits mode carries
+// over from the previous file.
+func Assemble(filename string, data []byte) ([]byte, bool) {
+	current_filename = filename
+	// Lay out everything to find which branches can be short, the first
+	// layout only finds the width of the labels
+	bits := Bits32
+	Widths = map[string]int {}
+	ShortBranches = map[int]bool {}
+	layout(string(data), bits)
+	layout(string(data), bits)
+	relax()
+	DataBuffer = []byte {}
+	TextBuffer = []byte {}
+	ExtendedDataBuffer = []byte {}
+	section = "text"
+	Bits32 = bits
+	Offsets = map[string]int {}
+	Unknown = map[string]int {}
+	Labels = map[string]location {}
+	Branches = []branch {}
+	BranchCount = 0
+	// Assemble everything
+	assemble(string(data))
+	// Error checking
+	var error_str string = ""
+	if Warnings > 0 {
+		error_str = error_str + fmt.Sprintf("%d", Warnings) + " warning"
+		if Warnings > 1 {
+			error_str = error_str + "s"
+		}
+		if Errors > 0 {
+			error_str = error_str + " and "
+		} else {
+			error_str = error_str + " generated."
+		}
+	}
+	if Errors > 0 {
+		error_str = error_str + fmt.Sprintf("%d", Errors) + " error"
+		if Errors > 1 {
+			error_str = error_str + "s"
+		}
+		error_str = error_str + " generated."
+	}
+	if Errors > 0 || Warnings > 0 {
+		fmt.Println(error_str)
+	}
+	failed := Errors > 0
+	buffer := append([]byte{0xc2, 0x80, 0x7d}, append(DataBuffer, append([]byte{0xc2, 0x80, 0x7e}, append(TextBuffer, append([]byte{0xc2, 0x80, 0x7f}, ExtendedDataBuffer...)...)...)...)...)
+	// Reset
+	Errors = 0
+	Warnings = 0
+	DataBuffer = []byte {}
+	TextBuffer = []byte {}
+	ExtendedDataBuffer = []byte {}
+	section = "text"
+	Offsets = map[string]int {}
+	BranchCount = 0
+	return buffer, failed == false
+}
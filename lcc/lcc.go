@@ -23,7 +23,7 @@ func execute(command string, displayError bool) bool {
 
 	cmd := exec.Command(shell, flag, command)
 	output, err := cmd.CombinedOutput()
-	fmt.Printf(string(output))
+	fmt.Print(string(output))
 
 	if err != nil {
 		if displayError == true {
@@ -66,6 +66,7 @@ func main() {
 
 	var nolink bool = false
 	var noassemble bool = false
+	var pie bool = false
 	var input_files = []string {}
 	var cleanup = []string {}
 	var output_file string = ""
@@ -87,6 +88,8 @@ func main() {
 			os.Exit(0)
 		case "-S":
 			noassemble = true	
+		case "-pie":
+			pie = true
 		default:
 			input_files = append(input_files, arg)
 		}
@@ -158,7 +161,11 @@ func main() {
 	
 	// Third pass: link all assembly files to final executable
 
-	success := execute("l2ld " + strings.Join(object_files, " ") + " -o " + output_file, false)
+	flags := ""
+	if pie == true {
+		flags = " -pie"
+	}
+	success := execute("l2ld " + strings.Join(object_files, " ") + flags + " -o " + output_file, false)
 	if success != true {
 		cleanupFiles(cleanup)
 		stderr("\033[1;39mlcc: \033[1;31merror: \033[1;39mlinker command failed.\033[0m")
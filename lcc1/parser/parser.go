@@ -199,6 +199,7 @@ func Parse(tokens []lexer.Token) {
 				if peek(0).Type == lexer.TokLParen {	
 					expect(lexer.TokLParen)
 					var expComma bool = false
+					var args int = 0
 					for j := i; j < len(tokens); j++ {
 						if tokens[j].Type == lexer.TokRParen {
 							i = j
@@ -218,9 +219,11 @@ func Parse(tokens []lexer.Token) {
 								CreateStatic(Variable_Static{Name: "var_" + fmt.Sprintf("%d", IDCounter), Type: STRING, Value: str})
 								Write("push var_" + fmt.Sprintf("%d", IDCounter), true)
 								IDCounter++
+								args++
 								expComma = true
 							} else {
 								Write("push " + tokens[j].Value, true)
+								args++
 								expComma = true
 							}
 						}
@@ -229,6 +232,10 @@ func Parse(tokens []lexer.Token) {
 					expect(lexer.TokRParen)
 					expect(lexer.TokSemi)
 					Write("call " + name, true)
+					// The caller removes its arguments once the call returns
+					for k := 0; k < args; k++ {
+						Write("pop t1", true)
+					}
 				} 
 			case lexer.TokReturn:
 				expect(lexer.TokReturn)